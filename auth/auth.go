@@ -0,0 +1,69 @@
+// Package auth decide which participant can publish, subscribe a track or create a datachannel
+package auth
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spgnk/rtc/errs"
+)
+
+// Action checked by Authorizer
+const (
+	ActionPublish     = "publish"
+	ActionSubscribe   = "subscribe"
+	ActionDataChannel = "datachannel"
+)
+
+// ErrDenied is wrapped by every error of a denial
+var ErrDenied = errs.ErrA001
+
+// Authorizer is consulted by worker before a track is published, subscribed or a datachannel peer is created.
+// Return nil to allow, an error wrapping ErrDenied to deny, any other error is a failure of the check
+type Authorizer interface {
+	CanPublish(signalID, peerConnectionID, trackID, kind string) error
+	CanSubscribe(signalID, peerConnectionID, trackID, kind string) error
+	CanCreateDataChannel(signalID, peerConnectionID string) error
+}
+
+// Error is returned by worker when Authorizer reject an action
+type Error struct {
+	Action           string
+	SignalID         string
+	PeerConnectionID string
+	TrackID          string // empty for datachannel
+	Err              error
+}
+
+// NewError linter
+func NewError(action, signalID, peerConnectionID, trackID string, err error) *Error {
+	return &Error{
+		Action:           action,
+		SignalID:         signalID,
+		PeerConnectionID: peerConnectionID,
+		TrackID:          trackID,
+		Err:              err,
+	}
+}
+
+func (e *Error) Error() string {
+	if e.TrackID == "" {
+		return fmt.Sprintf("[%s-%s] %s: %s", e.SignalID, e.PeerConnectionID, e.Action, e.Err.Error())
+	}
+	return fmt.Sprintf("[%s-%s] %s %s: %s", e.SignalID, e.PeerConnectionID, e.Action, e.TrackID, e.Err.Error())
+}
+
+// Unwrap linter
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// IsDenied report whether err is a denial, false for failure of the check
+func IsDenied(err error) bool {
+	return errors.Is(err, ErrDenied)
+}
+
+// Denied return a denial error with reason
+func Denied(reason string) error {
+	return fmt.Errorf("%w: %s", ErrDenied, reason)
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spgnk/rtc/errs"
+)
+
+var _ (Authorizer) = (*ClaimsAuthorizer)(nil)
+
+// Claims permission of a signalID. Track pattern use path.Match syntax, "*" match every trackID
+type Claims struct {
+	SignalID    string    `json:"signal_id"`
+	Roles       []string  `json:"roles,omitempty"`     // app role, e.g. teacher or student
+	Publish     []string  `json:"publish,omitempty"`   // trackID pattern allowed to publish
+	Subscribe   []string  `json:"subscribe,omitempty"` // trackID pattern allowed to subscribe
+	DataChannel bool      `json:"datachannel,omitempty"`
+	ExpiresAt   time.Time `json:"-"` // every action is denied after, zero never expire
+}
+
+// Expired report whether claims is no longer valid at now
+func (c *Claims) Expired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
+}
+
+// Policy permission granted to every claims having the role
+type Policy struct {
+	Publish     []string // trackID pattern allowed to publish
+	Subscribe   []string // trackID pattern allowed to subscribe
+	DataChannel bool
+}
+
+// ClaimsAuthorizer default Authorizer. Action is allowed by claims of signalID or by policy of one of its roles.
+// signalID without claims is denied
+type ClaimsAuthorizer struct {
+	claims   map[string]*Claims // save signalID - claims
+	policies map[string]*Policy // save role - policy
+	mutex    sync.RWMutex
+}
+
+// NewClaimsAuthorizer linter
+func NewClaimsAuthorizer() *ClaimsAuthorizer {
+	return &ClaimsAuthorizer{
+		claims:   make(map[string]*Claims),
+		policies: make(map[string]*Policy),
+	}
+}
+
+// SetPolicy set permission of role
+func (a *ClaimsAuthorizer) SetPolicy(role string, policy *Policy) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.policies[role] = policy
+}
+
+// DeletePolicy linter
+func (a *ClaimsAuthorizer) DeletePolicy(role string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.policies, role)
+}
+
+// SetClaims set claims of claims.SignalID
+func (a *ClaimsAuthorizer) SetClaims(claims *Claims) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.claims[claims.SignalID] = claims
+}
+
+// GetClaims return nil if signalID has no claims
+func (a *ClaimsAuthorizer) GetClaims(signalID string) *Claims {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.claims[signalID]
+}
+
+// DeleteClaims linter
+func (a *ClaimsAuthorizer) DeleteClaims(signalID string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.claims, signalID)
+}
+
+// CanPublish linter
+func (a *ClaimsAuthorizer) CanPublish(signalID, peerConnectionID, trackID, kind string) error {
+	return a.check(signalID, func(c *Claims) bool {
+		return matchAny(c.Publish, trackID)
+	}, func(p *Policy) bool {
+		return matchAny(p.Publish, trackID)
+	}, ActionPublish, trackID)
+}
+
+// CanSubscribe linter
+func (a *ClaimsAuthorizer) CanSubscribe(signalID, peerConnectionID, trackID, kind string) error {
+	return a.check(signalID, func(c *Claims) bool {
+		return matchAny(c.Subscribe, trackID)
+	}, func(p *Policy) bool {
+		return matchAny(p.Subscribe, trackID)
+	}, ActionSubscribe, trackID)
+}
+
+// CanCreateDataChannel linter
+func (a *ClaimsAuthorizer) CanCreateDataChannel(signalID, peerConnectionID string) error {
+	return a.check(signalID, func(c *Claims) bool {
+		return c.DataChannel
+	}, func(p *Policy) bool {
+		return p.DataChannel
+	}, ActionDataChannel, "")
+}
+
+// Permissions report whether signalID may publish or subscribe any track and create datachannel
+func (a *ClaimsAuthorizer) Permissions(signalID string) (publish, subscribe, dataChannel bool) {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	c := a.claims[signalID]
+	if c == nil || c.Expired(time.Now()) {
+		return false, false, false
+	}
+	publish, subscribe, dataChannel = len(c.Publish) > 0, len(c.Subscribe) > 0, c.DataChannel
+	for _, role := range c.Roles {
+		if p := a.policies[role]; p != nil {
+			publish = publish || len(p.Publish) > 0
+			subscribe = subscribe || len(p.Subscribe) > 0
+			dataChannel = dataChannel || p.DataChannel
+		}
+	}
+	return publish, subscribe, dataChannel
+}
+
+func (a *ClaimsAuthorizer) check(signalID string, byClaims func(c *Claims) bool, byPolicy func(p *Policy) bool, action, trackID string) error {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	c := a.claims[signalID]
+	if c == nil {
+		return fmt.Errorf("%w: %s %s", ErrDenied, signalID, errs.ErrA002.Error())
+	}
+	if c.Expired(time.Now()) {
+		return fmt.Errorf("%w: %s %s", ErrDenied, signalID, errs.ErrA003.Error())
+	}
+	if byClaims(c) {
+		return nil
+	}
+	for _, role := range c.Roles {
+		if p := a.policies[role]; p != nil && byPolicy(p) {
+			return nil
+		}
+	}
+	return Denied(strings.TrimSpace(fmt.Sprintf("%s is not allowed to %s %s", signalID, action, trackID)))
+}
+
+// matchAny report whether trackID match one of patterns, malformed pattern match nothing
+func matchAny(patterns []string, trackID string) bool {
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, trackID); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+package errs
+
+import "fmt"
+
+// error
+var (
+	// ErrA001 linter
+	ErrA001 = fmt.Errorf("A001")
+	// ErrA002 linter
+	ErrA002 = fmt.Errorf("A002")
+	// ErrA003 linter
+	ErrA003 = fmt.Errorf("A003")
+)
@@ -22,4 +22,8 @@ var (
 	ErrP005 = fmt.Errorf("P005")
 	// ErrP006 linter
 	ErrP006 = fmt.Errorf("P006")
+	// ErrP007 linter
+	ErrP007 = fmt.Errorf("P007")
+	// ErrP008 linter
+	ErrP008 = fmt.Errorf("P008")
 )
@@ -0,0 +1,17 @@
+package errs
+
+import "fmt"
+
+// error
+var (
+	// ErrT001 linter
+	ErrT001 = fmt.Errorf("T001")
+	// ErrT002 linter
+	ErrT002 = fmt.Errorf("T002")
+	// ErrT003 linter
+	ErrT003 = fmt.Errorf("T003")
+	// ErrT004 linter
+	ErrT004 = fmt.Errorf("T004")
+	// ErrT005 linter
+	ErrT005 = fmt.Errorf("T005")
+)
@@ -6,4 +6,14 @@ import "fmt"
 var (
 	// ErrW001 linter
 	ErrW001 = fmt.Errorf("W001")
+	// ErrW002 linter
+	ErrW002 = fmt.Errorf("W002")
+	// ErrW003 linter
+	ErrW003 = fmt.Errorf("W003")
+	// ErrW004 linter
+	ErrW004 = fmt.Errorf("W004")
+	// ErrW005 linter
+	ErrW005 = fmt.Errorf("W005")
+	// ErrW006 linter
+	ErrW006 = fmt.Errorf("W006")
 )
@@ -11,6 +11,7 @@ require (
 	github.com/pion/rtp v1.8.0
 	github.com/pion/webrtc/v3 v3.2.14
 	github.com/segmentio/ksuid v1.0.4
+	golang.org/x/net v0.12.0
 )
 
 require (
@@ -32,7 +33,6 @@ require (
 	github.com/sirupsen/logrus v1.9.3 // indirect
 	github.com/stretchr/testify v1.8.4 // indirect
 	golang.org/x/crypto v0.11.0 // indirect
-	golang.org/x/net v0.12.0 // indirect
 	golang.org/x/sys v0.10.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -26,6 +26,9 @@ type Configs struct {
 
 	IsCreateDC bool
 
+	// Polite peer rollback its offer on glare, impolite peer ignore remote offer
+	Polite bool
+
 	// Logger int // init logger
 }
 
@@ -33,16 +36,46 @@ type Configs struct {
 type TrackConfig struct {
 	kind              *string // rtp or sample track
 	trackID           *string
-	codec             string  // vp8/vp9/h264
-	profileID         int     // for codec profile id
-	role              *string // up or down
+	codec             string                         // vp8/vp9/h264/h265/av1
+	profileID         int                            // for codec profile id
+	role              *string                        // up or down
+	source            string                         // camera, screen or custom
+	direction         webrtc.RTPTransceiverDirection // sendonly or sendrecv, unknown use default of role
 	videoRTCPFeedback []webrtc.RTCPFeedback
 }
 
+// SetSource set source (camera/screen/custom) of track
+func (t *TrackConfig) SetSource(source string) *TrackConfig {
+	t.source = source
+	return t
+}
+
+// GetSource linter
+func (t *TrackConfig) GetSource() string {
+	return t.source
+}
+
+// SetDirection set direction of transceiver sending the track, sendonly or sendrecv
+func (t *TrackConfig) SetDirection(direction webrtc.RTPTransceiverDirection) *TrackConfig {
+	t.direction = direction
+	return t
+}
+
+// GetDirection return direction of transceiver. Peer up send only, peer down keep recv to match sendrecv offer of client
+func (t *TrackConfig) GetDirection() webrtc.RTPTransceiverDirection {
+	if t.direction != webrtc.RTPTransceiverDirection(webrtc.Unknown) {
+		return t.direction
+	}
+	if t.role != nil && *t.role == utils.PeerUp {
+		return webrtc.RTPTransceiverDirectionSendonly
+	}
+	return webrtc.RTPTransceiverDirectionSendrecv
+}
+
 // NewTrackConfig linter
 func NewTrackConfig(
 	trackID *string,
-	codec string, // vp8/vp9/h264
+	codec string, // vp8/vp9/h264/h265/av1
 	role *string,
 	kind *string,
 ) *TrackConfig {
@@ -69,6 +102,14 @@ func (t *TrackConfig) GetRTPCodecCapability(payloadType *int) []webrtc.RTPCodecP
 		return t.getVP8RTPCodecCapability(payloadType)
 	case utils.ModeVP9:
 		return t.getVP9RTPCodecCapability(payloadType)
+	case utils.ModeH264:
+		return t.getH264RTPCodecCapability(payloadType)
+	case utils.ModeH265:
+		return t.getH265RTPCodecCapability(payloadType)
+	case utils.ModeAV1:
+		return t.getAV1RTPCodecCapability(payloadType)
+	case utils.ModeOpus:
+		return t.getOpusRTPCodecCapability(payloadType)
 	default:
 		return t.getVP9RTPCodecCapability(payloadType)
 	}
@@ -123,3 +164,64 @@ func (t *TrackConfig) getVP9RTPCodecCapability(payloadType *int) []webrtc.RTPCod
 		// },
 	}
 }
+
+func (t *TrackConfig) getH264RTPCodecCapability(payloadType *int) []webrtc.RTPCodecParameters {
+	return []webrtc.RTPCodecParameters{
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:     utils.MimeTypeH264,
+				ClockRate:    90000,
+				Channels:     0,
+				SDPFmtpLine:  "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f",
+				RTCPFeedback: t.videoRTCPFeedback},
+			PayloadType: webrtc.PayloadType(*payloadType),
+		},
+	}
+}
+
+// getH265RTPCodecCapability use profileID as hevc profile-id, 0 is main profile
+func (t *TrackConfig) getH265RTPCodecCapability(payloadType *int) []webrtc.RTPCodecParameters {
+	profileID := t.profileID
+	if profileID == 0 {
+		profileID = utils.H265ProfileMain
+	}
+	return []webrtc.RTPCodecParameters{
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:     utils.MimeTypeH265,
+				ClockRate:    90000,
+				Channels:     0,
+				SDPFmtpLine:  utils.H265Fmtp(profileID),
+				RTCPFeedback: t.videoRTCPFeedback},
+			PayloadType: webrtc.PayloadType(*payloadType),
+		},
+	}
+}
+
+func (t *TrackConfig) getAV1RTPCodecCapability(payloadType *int) []webrtc.RTPCodecParameters {
+	return []webrtc.RTPCodecParameters{
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:     utils.MimeTypeAV1,
+				ClockRate:    90000,
+				Channels:     0,
+				SDPFmtpLine:  "",
+				RTCPFeedback: t.videoRTCPFeedback},
+			PayloadType: webrtc.PayloadType(*payloadType),
+		},
+	}
+}
+
+func (t *TrackConfig) getOpusRTPCodecCapability(payloadType *int) []webrtc.RTPCodecParameters {
+	return []webrtc.RTPCodecParameters{
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:    utils.MimeTypeOpus,
+				ClockRate:   48000,
+				Channels:    2,
+				SDPFmtpLine: "minptime=10;useinbandfec=1",
+			},
+			PayloadType: webrtc.PayloadType(*payloadType),
+		},
+	}
+}
@@ -15,6 +15,7 @@ type Connection interface {
 	GetCookieID() *string
 	GetPeerConnectionID() *string
 	GetLocalDescription() (*webrtc.SessionDescription, error)
+	GatheringCompletePromise() <-chan struct{}
 
 	GetAudioRTPTrack(trackID *string) *webrtc.TrackLocalStaticRTP
 	GetVideoRTPTrack(trackID *string) *webrtc.TrackLocalStaticRTP
@@ -23,6 +24,9 @@ type Connection interface {
 	ReplaceVideoTrack(trackID, codec *string) error
 
 	CreateOffer(iceRestart bool) error
+	// Renegotiate create an offer for negotiation handler, postponed until signaling state is stable
+	Renegotiate() error
+	SetNegotiationHandler(handler func(offer *webrtc.SessionDescription))
 	CreateAnswer() error
 	Close()
 
@@ -38,13 +42,33 @@ type Connection interface {
 
 	AddVideoRTP(trackID, peerConnectionID *string, packet *rtp.Packet) error
 	AddAudioRTP(trackID, peerConnectionID *string, packet *rtp.Packet) error
+	SkipVideoRTP(trackID *string, packet *rtp.Packet)
+	// SetCodecSource set handler return codecs of publisher feeding local track, use to map payload type of subscriber
+	SetCodecSource(source func(trackID string) []webrtc.RTPCodecParameters)
+	// SetPacketSource set handler return cached source packet to answer NACK of subscriber
+	SetPacketSource(source func(trackID string, ssrc uint32, seq uint16) ([]byte, bool))
+	// SetKeyframeRequestHandler set handler receive PLI/FIR of subscriber
+	SetKeyframeRequestHandler(handler func(trackID string))
 	AddVideoSample(trackID, peerConnectionID *string, sample *media.Sample) error
 	AddAudioSample(trackID, peerConnectionID *string, sample *media.Sample) error
 
 	HandleVideoTrack(remoteTrack *webrtc.TrackRemote)
-
-	// SetCodecPreferences sets preferred list of supported codecs
-	// if codecs is empty or nil we reset to default from MediaEngine
+	// GetHeaderExtensionID return negotiated id of header extension uri of remoteTrack
+	GetHeaderExtensionID(remoteTrack *webrtc.TrackRemote, uri string) uint8
+	// GetTrackSource return source (camera/screen/custom) of local track trackID
+	GetTrackSource(trackID *string) string
+	// GetRemoteCodecs return codecs negotiated by transceiver receiving remoteTrack
+	GetRemoteCodecs(remoteTrack *webrtc.TrackRemote) []webrtc.RTPCodecParameters
+	// GetMid return mid of transceiver receiving remoteTrack
+	GetMid(remoteTrack *webrtc.TrackRemote) string
+
+	// SwitchSource mark local track is switching to a new source
+	SwitchSource(trackID *string)
+	// ResyncSource keep seq/timestamp continuous when local track is resumed
+	ResyncSource(trackID *string)
+
+	// SetCodecPreferences sets codec of transceiver sending trackConfig track, call it after add track and before offer/answer.
+	// nil payLoadType use payload type of MediaEngine
 	SetCodecPreferences(payLoadType *int, trackConfig *TrackConfig) error
 
 	IsConnected() bool
@@ -60,12 +84,22 @@ type Connection interface {
 	) (*webrtc.PeerConnection, error)
 
 	SendPictureLossIndication()
+	SendPictureLossIndicationTo(ssrc uint32)
 
 	AddDuplicated(t string, element bool)
 	GetDuplicated(t string) bool
 	DeleteDuplicated(t string)
 
-	// SetPliInterval linter
+	// EstimatedBitrate return estimated bandwidth of peer down in bps
+	EstimatedBitrate() int
+	// SetBitrateHandler set handler receive new estimated bitrate of peer down
+	SetBitrateHandler(handler func(bitrate int))
+	// ReceiverBitrate return last REMB bitrate of peer down in bps
+	ReceiverBitrate() int
+	// SetBitrate set REMB bitrate (kbps) send to peer up, 0 is no limit
+	SetBitrate(bitrate int)
+
+	// SetPliInterval 0 is default 30s, negative value disable periodic PLI
 	SetPliInterval(int)
 
 	// init logger
@@ -5,6 +5,9 @@ import (
 	"strings"
 
 	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/interceptor/pkg/gcc"
+	"github.com/pion/interceptor/pkg/nack"
 	"github.com/pion/webrtc/v3"
 	"github.com/spgnk/rtc/utils"
 )
@@ -22,10 +25,18 @@ func (p *Peer) initAPI(config *Configs) (*webrtc.API, error) {
 	// for each PeerConnection.
 	i := &interceptor.Registry{}
 
-	// Use the default set of Interceptors
-	if err := webrtc.RegisterDefaultInterceptors(m, i); err != nil {
-		// logs.Error("initAPI RegisterDefaultInterceptors error: ", err.Error())
-		return nil, err
+	switch *config.Role {
+	case utils.PeerDown:
+		// NACK of subscriber is answered from fwd cache, no need default nack responder
+		if err := p.registerDownInterceptors(m, i); err != nil {
+			return nil, err
+		}
+	default:
+		// Use the default set of Interceptors
+		if err := webrtc.RegisterDefaultInterceptors(m, i); err != nil {
+			// logs.Error("initAPI RegisterDefaultInterceptors error: ", err.Error())
+			return nil, err
+		}
 	}
 
 	return webrtc.NewAPI(
@@ -34,6 +45,41 @@ func (p *Peer) initAPI(config *Configs) (*webrtc.API, error) {
 		webrtc.WithSettingEngine(*p.initSettingEngine(config))), nil
 }
 
+// registerDownInterceptors is default interceptors without nack responder
+func (p *Peer) registerDownInterceptors(m *webrtc.MediaEngine, i *interceptor.Registry) error {
+	generator, err := nack.NewGeneratorInterceptor()
+	if err != nil {
+		return err
+	}
+	m.RegisterFeedback(webrtc.RTCPFeedback{Type: "nack"}, webrtc.RTPCodecTypeVideo)
+	m.RegisterFeedback(webrtc.RTCPFeedback{Type: "nack", Parameter: "pli"}, webrtc.RTPCodecTypeVideo)
+	i.Add(generator)
+
+	if err := webrtc.ConfigureRTCPReports(i); err != nil {
+		return err
+	}
+	if err := p.registerBandwidthEstimator(m, i); err != nil {
+		return err
+	}
+	return webrtc.ConfigureTWCCSender(m, i)
+}
+
+// registerBandwidthEstimator estimate bandwidth of subscriber with GCC from TWCC feedback.
+// Packet is not paced, estimation is only used to choose what to forward
+func (p *Peer) registerBandwidthEstimator(m *webrtc.MediaEngine, i *interceptor.Registry) error {
+	congestionController, err := cc.NewInterceptor(func() (cc.BandwidthEstimator, error) {
+		return gcc.NewSendSideBWE(gcc.SendSideBWEPacer(gcc.NewNoOpPacer()))
+	})
+	if err != nil {
+		return err
+	}
+	congestionController.OnNewPeerConnection(func(id string, estimator cc.BandwidthEstimator) {
+		p.setEstimator(estimator)
+	})
+	i.Add(congestionController)
+	return webrtc.ConfigureTWCCHeaderExtensionSender(m, i)
+}
+
 func (p *Peer) initSettingEngine(config *Configs) *webrtc.SettingEngine {
 	settingEngine := &webrtc.SettingEngine{}
 
@@ -102,25 +148,66 @@ func (p *Peer) initMediaEngine(config *Configs) (*webrtc.MediaEngine, error) {
 				if err != nil {
 					return nil, err
 				}
+			case utils.ModeH265:
+				if config.PayloadType == 0 {
+					config.PayloadType = utils.DefaultPayloadH265
+				}
+				err := p.registerH265(mediaEngine, config.PayloadType, videoRTCPFeedback)
+				if err != nil {
+					return nil, err
+				}
+				// register opus
+				err = p.registerOpus(mediaEngine)
+				if err != nil {
+					return nil, err
+				}
+			case utils.ModeAV1:
+				if config.PayloadType == 0 {
+					config.PayloadType = utils.DefaultPayloadAV1
+				}
+				err := p.registerAV1(mediaEngine, config.PayloadType, videoRTCPFeedback)
+				if err != nil {
+					return nil, err
+				}
+				// register opus
+				err = p.registerOpus(mediaEngine)
+				if err != nil {
+					return nil, err
+				}
 			default:
-				err := mediaEngine.RegisterDefaultCodecs()
+				err := p.registerDefaultCodecs(mediaEngine, videoRTCPFeedback)
 				if err != nil {
 					return nil, err
 				}
 			}
 		} else {
-			err := mediaEngine.RegisterDefaultCodecs()
+			err := p.registerDefaultCodecs(mediaEngine, videoRTCPFeedback)
 			if err != nil {
 				return nil, err
 			}
 		}
 	case utils.PeerUp:
-		err := mediaEngine.RegisterDefaultCodecs()
+		err := p.registerDefaultCodecs(mediaEngine, videoRTCPFeedback)
+		if err != nil {
+			return nil, err
+		}
+		// simulcast publisher need mid/rid extension to demux layer
+		err = p.registerSimulcastExtensions(mediaEngine)
+		if err != nil {
+			return nil, err
+		}
+		// dependency descriptor of av1 publisher use to drop svc layer
+		err = mediaEngine.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: utils.DependencyDescriptorURI}, webrtc.RTPCodecTypeVideo)
+		if err != nil {
+			return nil, err
+		}
+		// audio level of publisher use for active speaker detection
+		err = mediaEngine.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: utils.AudioLevelURI}, webrtc.RTPCodecTypeAudio)
 		if err != nil {
 			return nil, err
 		}
 	default:
-		err := mediaEngine.RegisterDefaultCodecs()
+		err := p.registerDefaultCodecs(mediaEngine, videoRTCPFeedback)
 		if err != nil {
 			return nil, err
 		}
@@ -128,6 +215,27 @@ func (p *Peer) initMediaEngine(config *Configs) (*webrtc.MediaEngine, error) {
 	return mediaEngine, nil
 }
 
+// registerDefaultCodecs is pion default codecs with hevc, pion does not register hevc by default
+func (p *Peer) registerDefaultCodecs(m *webrtc.MediaEngine, videoRTCPFeedback []webrtc.RTCPFeedback) error {
+	if err := m.RegisterDefaultCodecs(); err != nil {
+		return err
+	}
+	return p._addH265(m, utils.DefaultPayloadH265, videoRTCPFeedback)
+}
+
+func (p *Peer) registerSimulcastExtensions(m *webrtc.MediaEngine) error {
+	for _, extension := range []string{
+		"urn:ietf:params:rtp-hdrext:sdes:mid",
+		"urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id",
+		"urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id",
+	} {
+		if err := m.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: extension}, webrtc.RTPCodecTypeVideo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *Peer) registerVP8(m *webrtc.MediaEngine, payload int, videoRTCPFeedback []webrtc.RTCPFeedback) error {
 	err := p._addVP8(m, payload, videoRTCPFeedback)
 	if err != nil {
@@ -197,6 +305,100 @@ func (p *Peer) registerVP9(m *webrtc.MediaEngine, payload int, videoRTCPFeedback
 	return nil
 }
 
+func (p *Peer) registerH265(m *webrtc.MediaEngine, payload int, videoRTCPFeedback []webrtc.RTCPFeedback) error {
+	if err := p._addH265(m, payload, videoRTCPFeedback); err != nil {
+		return err
+	}
+
+	// Default Pion Video Header Extensions
+	for _, extension := range []string{
+		"urn:ietf:params:rtp-hdrext:sdes:mid",
+		"urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id",
+		"urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id",
+	} {
+		if err := m.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: extension}, webrtc.RTPCodecTypeVideo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// _addH265 register main profile at payload and main 10 profile at payload+2, each with its rtx
+func (p *Peer) _addH265(m *webrtc.MediaEngine, payload int, videoRTCPFeedback []webrtc.RTCPFeedback) error {
+	for i, profileID := range []int{utils.H265ProfileMain, utils.H265ProfileMain10} {
+		pt := payload + 2*i
+		for _, codec := range []webrtc.RTPCodecParameters{
+			{
+				RTPCodecCapability: webrtc.RTPCodecCapability{
+					MimeType:     utils.MimeTypeH265,
+					ClockRate:    90000,
+					Channels:     0,
+					SDPFmtpLine:  utils.H265Fmtp(profileID),
+					RTCPFeedback: videoRTCPFeedback,
+				},
+				PayloadType: webrtc.PayloadType(pt),
+			},
+			{
+				RTPCodecCapability: webrtc.RTPCodecCapability{
+					MimeType:     "video/rtx",
+					ClockRate:    90000,
+					Channels:     0,
+					SDPFmtpLine:  fmt.Sprintf("apt=%d", pt),
+					RTCPFeedback: nil,
+				},
+				PayloadType: webrtc.PayloadType(pt + 1),
+			},
+		} {
+			if err := m.RegisterCodec(codec, webrtc.RTPCodecTypeVideo); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Peer) registerAV1(m *webrtc.MediaEngine, payload int, videoRTCPFeedback []webrtc.RTCPFeedback) error {
+	for _, codec := range []webrtc.RTPCodecParameters{
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:     utils.MimeTypeAV1,
+				ClockRate:    90000,
+				Channels:     0,
+				SDPFmtpLine:  "",
+				RTCPFeedback: videoRTCPFeedback,
+			},
+			PayloadType: webrtc.PayloadType(payload),
+		},
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:     "video/rtx",
+				ClockRate:    90000,
+				Channels:     0,
+				SDPFmtpLine:  fmt.Sprintf("apt=%d", payload),
+				RTCPFeedback: nil,
+			},
+			PayloadType: webrtc.PayloadType(payload + 1),
+		},
+	} {
+		if err := m.RegisterCodec(codec, webrtc.RTPCodecTypeVideo); err != nil {
+			return err
+		}
+	}
+
+	// Default Pion Video Header Extensions with av1 dependency descriptor
+	for _, extension := range []string{
+		"urn:ietf:params:rtp-hdrext:sdes:mid",
+		"urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id",
+		"urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id",
+		utils.DependencyDescriptorURI,
+	} {
+		if err := m.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: extension}, webrtc.RTPCodecTypeVideo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (p *Peer) registerOpus(m *webrtc.MediaEngine) error {
 	for _, codec := range []webrtc.RTPCodecParameters{
 		{
@@ -226,6 +428,7 @@ func (p *Peer) registerOpus(m *webrtc.MediaEngine) error {
 		"urn:ietf:params:rtp-hdrext:sdes:mid",
 		"urn:ietf:params:rtp-hdrext:sdes:rtp-stream-id",
 		"urn:ietf:params:rtp-hdrext:sdes:repaired-rtp-stream-id",
+		utils.AudioLevelURI,
 	} {
 		if err := m.RegisterHeaderExtension(webrtc.RTPHeaderExtensionCapability{URI: extension}, webrtc.RTPCodecTypeAudio); err != nil {
 			return err
@@ -0,0 +1,147 @@
+package peer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/spgnk/rtc/errs"
+)
+
+// negotiationDelay debounce negotiation needed of many track change into one offer
+const negotiationDelay = 50 * time.Millisecond
+
+// SetNegotiationHandler set handler receive offer of renegotiation, the answer is set by AddSDP.
+// Without handler, negotiation needed is ignored and app create offer itself
+func (p *Peer) SetNegotiationHandler(handler func(offer *webrtc.SessionDescription)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.negotiationHandler = handler
+}
+
+// Renegotiate create a new offer and send it to negotiation handler.
+// If an offer/answer is in progress, renegotiation run again once signaling state is stable
+func (p *Peer) Renegotiate() error {
+	offer, err := p.renegotiate()
+	if err != nil || offer == nil {
+		return err
+	}
+	if handler := p.getNegotiationHandler(); handler != nil {
+		handler(p.signalRTX(offer))
+	}
+	return nil
+}
+
+// renegotiate return nil offer if renegotiation is postponed
+func (p *Peer) renegotiate() (*webrtc.SessionDescription, error) {
+	p.sdpMutex.Lock()
+	defer p.sdpMutex.Unlock()
+
+	conn := p.getConn()
+	if conn == nil {
+		return nil, errs.ErrP002
+	}
+	if conn.SignalingState() != webrtc.SignalingStateStable {
+		p.setPendingNegotiation(true)
+		return nil, nil
+	}
+	p.setPendingNegotiation(false)
+
+	offer, err := conn.CreateOffer(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetLocalDescription(offer); err != nil {
+		return nil, err
+	}
+	return &offer, nil
+}
+
+// handleNegotiationNeeded debounce negotiation needed event of pion
+func (p *Peer) handleNegotiationNeeded() {
+	if p.getNegotiationHandler() == nil || p.checkClose() {
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	// timer not fired yet is only postponed
+	if p.negotiationTimer != nil && p.negotiationTimer.Stop() {
+		p.negotiationTimer.Reset(negotiationDelay)
+		return
+	}
+
+	// timer fired and waiting for mutex is outdated by the new one
+	p.negotiationGen++
+	gen := p.negotiationGen
+	p.negotiationTimer = time.AfterFunc(negotiationDelay, func() {
+		p.mutex.Lock()
+		if gen != p.negotiationGen {
+			p.mutex.Unlock()
+			return
+		}
+		p.negotiationTimer = nil
+		p.mutex.Unlock()
+
+		if err := p.Renegotiate(); err != nil {
+			p.Error(fmt.Sprintf("renegotiate err: %s", err.Error()), nil)
+		}
+	})
+}
+
+// resumeNegotiation run postponed renegotiation once signaling state is stable
+func (p *Peer) resumeNegotiation() {
+	conn := p.getConn()
+	if conn == nil || conn.SignalingState() != webrtc.SignalingStateStable {
+		return
+	}
+	if p.getPendingNegotiation() {
+		p.handleNegotiationNeeded()
+	}
+}
+
+func (p *Peer) stopNegotiationTimer() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.negotiationGen++
+	if p.negotiationTimer != nil {
+		p.negotiationTimer.Stop()
+		p.negotiationTimer = nil
+	}
+}
+
+func (p *Peer) getNegotiationHandler() func(offer *webrtc.SessionDescription) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.negotiationHandler
+}
+
+func (p *Peer) setPendingNegotiation(pending bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.pendingNegotiation = pending
+}
+
+func (p *Peer) getPendingNegotiation() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.pendingNegotiation
+}
+
+func (p *Peer) setIgnoreOffer(ignore bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.ignoreOffer = ignore
+}
+
+func (p *Peer) isIgnoreOffer() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.ignoreOffer
+}
+
+func (p *Peer) isPolite() bool {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.config.Polite
+}
@@ -0,0 +1,268 @@
+package peer
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+const mimeTypeRTX = "video/rtx"
+
+// PayloadMap map payload type of publisher to payload type negotiated by subscriber
+type PayloadMap struct {
+	types map[uint8]uint8 // save publisher - subscriber payload type
+}
+
+// NewPayloadMap match every publisher codec with a subscriber codec of the same mimetype, fmtp first.
+// rtx is matched by the codec of its apt
+func NewPayloadMap(publisher, subscriber []webrtc.RTPCodecParameters) *PayloadMap {
+	m := &PayloadMap{
+		types: make(map[uint8]uint8),
+	}
+
+	var rtx []webrtc.RTPCodecParameters
+	for _, codec := range publisher {
+		if isRTX(&codec) {
+			rtx = append(rtx, codec)
+			continue
+		}
+		if match := matchCodec(&codec, subscriber); match != nil {
+			m.types[uint8(codec.PayloadType)] = uint8(match.PayloadType)
+		}
+	}
+
+	// apt codec is mapped before its rtx
+	for _, codec := range rtx {
+		apt, ok := getApt(&codec)
+		if !ok {
+			continue
+		}
+		mapped, ok := m.types[apt]
+		if !ok {
+			continue
+		}
+		for _, c := range subscriber {
+			if a, ok := getApt(&c); ok && isRTX(&c) && a == mapped {
+				m.types[uint8(codec.PayloadType)] = uint8(c.PayloadType)
+				break
+			}
+		}
+	}
+	return m
+}
+
+// Get return payload type of subscriber, false if subscriber has not negotiated the codec
+func (m *PayloadMap) Get(payloadType uint8) (uint8, bool) {
+	pt, ok := m.types[payloadType]
+	return pt, ok
+}
+
+// matchCodec return nil if no subscriber codec has the mimetype of codec
+func matchCodec(codec *webrtc.RTPCodecParameters, subscriber []webrtc.RTPCodecParameters) *webrtc.RTPCodecParameters {
+	for i := range subscriber {
+		if strings.EqualFold(subscriber[i].MimeType, codec.MimeType) && fmtpMatch(codec.MimeType, codec.SDPFmtpLine, subscriber[i].SDPFmtpLine) {
+			return &subscriber[i]
+		}
+	}
+	// same as pion, fallback to mimetype only
+	for i := range subscriber {
+		if strings.EqualFold(subscriber[i].MimeType, codec.MimeType) {
+			return &subscriber[i]
+		}
+	}
+	return nil
+}
+
+// fmtpMatch report whether parameters in both fmtp are equal.
+// h264 compare packetization-mode and profile of profile-level-id like pion
+func fmtpMatch(mimeType, a, b string) bool {
+	pa, pb := parseFmtp(a), parseFmtp(b)
+	if strings.EqualFold(mimeType, webrtc.MimeTypeH264) {
+		return pa["packetization-mode"] == pb["packetization-mode"] &&
+			h264Profile(pa["profile-level-id"]) == h264Profile(pb["profile-level-id"])
+	}
+	for key, value := range pa {
+		if other, ok := pb[key]; ok && !strings.EqualFold(value, other) {
+			return false
+		}
+	}
+	return true
+}
+
+func parseFmtp(line string) map[string]string {
+	params := make(map[string]string)
+	for _, param := range strings.Split(line, ";") {
+		pair := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if pair[0] == "" {
+			continue
+		}
+		value := ""
+		if len(pair) == 2 {
+			value = pair[1]
+		}
+		params[strings.ToLower(pair[0])] = value
+	}
+	return params
+}
+
+// h264Profile return profile_idc and profile_iop of profile-level-id
+func h264Profile(profileLevelID string) string {
+	if len(profileLevelID) < 4 {
+		return strings.ToLower(profileLevelID)
+	}
+	return strings.ToLower(profileLevelID[:4])
+}
+
+func isRTX(codec *webrtc.RTPCodecParameters) bool {
+	return strings.EqualFold(codec.MimeType, mimeTypeRTX)
+}
+
+func getApt(codec *webrtc.RTPCodecParameters) (uint8, bool) {
+	value, ok := parseFmtp(codec.SDPFmtpLine)["apt"]
+	if !ok {
+		return 0, false
+	}
+	apt, err := strconv.ParseUint(value, 10, 8)
+	if err != nil {
+		return 0, false
+	}
+	return uint8(apt), true
+}
+
+// rtpBinding is a subscriber sender bound to rtpTrack
+type rtpBinding struct {
+	id          string
+	ssrc        webrtc.SSRC
+	payloadType webrtc.PayloadType          // payload type of bound codec, used without publisher codecs
+	codecs      []webrtc.RTPCodecParameters // codecs negotiated by subscriber
+	payloads    *PayloadMap                 // nil until publisher codecs is known
+	rtxSeq      uint16                      // sequence number of rtx stream
+	writeStream webrtc.TrackLocalWriter
+}
+
+// mapPayloadType return payload type of subscriber for payloadType of publisher, false if codec is not negotiated
+func (b *rtpBinding) mapPayloadType(payloadType uint8) (uint8, bool) {
+	if b.payloads == nil {
+		return uint8(b.payloadType), true
+	}
+	return b.payloads.Get(payloadType)
+}
+
+// rtpTrack is TrackLocalStaticRTP writing packet with payload type mapped for each subscriber.
+// TrackLocalStaticRTP overwrite payload type with its bound codec, that break packet of other codec (red, ulpfec, rtx)
+// or subscriber negotiated different payload type for the codec
+type rtpTrack struct {
+	*webrtc.TrackLocalStaticRTP
+	publisher []webrtc.RTPCodecParameters // codecs of publisher feeding this track, nil is unknown
+	rtxSSRC   webrtc.SSRC                 // ssrc of retransmission, signaled by LocalTracks.signalRTX
+	bindings  []*rtpBinding
+	mutex     sync.RWMutex
+}
+
+func newRTPTrack(capability webrtc.RTPCodecCapability, id, streamID string) (*rtpTrack, error) {
+	track, err := webrtc.NewTrackLocalStaticRTP(capability, id, streamID)
+	if err != nil {
+		return nil, err
+	}
+	return &rtpTrack{
+		TrackLocalStaticRTP: track,
+		rtxSSRC:             webrtc.SSRC(rand.Uint32()),
+	}, nil
+}
+
+// Bind keep negotiated codecs of subscriber to map payload type
+func (t *rtpTrack) Bind(ctx webrtc.TrackLocalContext) (webrtc.RTPCodecParameters, error) {
+	codec, err := t.TrackLocalStaticRTP.Bind(ctx)
+	if err != nil {
+		return codec, err
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	b := &rtpBinding{
+		id:          ctx.ID(),
+		ssrc:        ctx.SSRC(),
+		payloadType: codec.PayloadType,
+		codecs:      ctx.CodecParameters(),
+		rtxSeq:      uint16(rand.Uint32()),
+		writeStream: ctx.WriteStream(),
+	}
+	if t.publisher != nil {
+		b.payloads = NewPayloadMap(t.publisher, b.codecs)
+	}
+	t.bindings = append(t.bindings, b)
+	return codec, nil
+}
+
+// Unbind linter
+func (t *rtpTrack) Unbind(ctx webrtc.TrackLocalContext) error {
+	t.mutex.Lock()
+	for i, b := range t.bindings {
+		if b.id == ctx.ID() {
+			t.bindings = append(t.bindings[:i], t.bindings[i+1:]...)
+			break
+		}
+	}
+	t.mutex.Unlock()
+	return t.TrackLocalStaticRTP.Unbind(ctx)
+}
+
+// WriteRTP write packet to every subscriber with its payload type, packet of codec not negotiated by subscriber is dropped
+func (t *rtpTrack) WriteRTP(p *rtp.Packet) error {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	var err error
+	for _, b := range t.bindings {
+		payloadType, ok := b.mapPayloadType(p.PayloadType)
+		if !ok {
+			continue
+		}
+
+		header := p.Header
+		header.SSRC = uint32(b.ssrc)
+		header.PayloadType = payloadType
+		if _, e := b.writeStream.WriteRTP(&header, p.Payload); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// accept return false if no subscriber negotiated the codec of payloadType
+func (t *rtpTrack) accept(payloadType uint8) bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	for _, b := range t.bindings {
+		if b.payloads == nil {
+			return true
+		}
+		if _, ok := b.payloads.Get(payloadType); ok {
+			return true
+		}
+	}
+	return len(t.bindings) == 0
+}
+
+// setPublisher set codecs of publisher feeding this track, nil reset mapping until the next publisher is known
+func (t *rtpTrack) setPublisher(codecs []webrtc.RTPCodecParameters) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.publisher = codecs
+	for _, b := range t.bindings {
+		b.payloads = nil
+		if codecs != nil {
+			b.payloads = NewPayloadMap(codecs, b.codecs)
+		}
+	}
+}
+
+func (t *rtpTrack) hasPublisher() bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.publisher != nil
+}
@@ -0,0 +1,198 @@
+package peer
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+func testCodec(mimeType string, payloadType uint8, fmtp string) webrtc.RTPCodecParameters {
+	return webrtc.RTPCodecParameters{
+		RTPCodecCapability: webrtc.RTPCodecCapability{
+			MimeType:    mimeType,
+			ClockRate:   90000,
+			SDPFmtpLine: fmtp,
+		},
+		PayloadType: webrtc.PayloadType(payloadType),
+	}
+}
+
+// publisherCodecs numbered like chrome
+func publisherCodecs() []webrtc.RTPCodecParameters {
+	return []webrtc.RTPCodecParameters{
+		testCodec(webrtc.MimeTypeVP8, 96, ""),
+		testCodec(mimeTypeRTX, 97, "apt=96"),
+		testCodec(webrtc.MimeTypeVP9, 98, "profile-id=0"),
+		testCodec(mimeTypeRTX, 99, "apt=98"),
+		testCodec(webrtc.MimeTypeH264, 102, "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f"),
+		testCodec(mimeTypeRTX, 103, "apt=102"),
+		testCodec(webrtc.MimeTypeH264, 104, "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=640032"),
+	}
+}
+
+// subscriberCodecs numbered like firefox, without vp9
+func subscriberCodecs() []webrtc.RTPCodecParameters {
+	return []webrtc.RTPCodecParameters{
+		testCodec(webrtc.MimeTypeH264, 97, "profile-level-id=42e01f;level-asymmetry-allowed=1;packetization-mode=0"),
+		testCodec(webrtc.MimeTypeH264, 126, "profile-level-id=42e01f;level-asymmetry-allowed=1;packetization-mode=1"),
+		testCodec(mimeTypeRTX, 127, "apt=126"),
+		testCodec(webrtc.MimeTypeH264, 105, "profile-level-id=64001f;level-asymmetry-allowed=1;packetization-mode=1"),
+		testCodec(webrtc.MimeTypeVP8, 120, ""),
+		testCodec(mimeTypeRTX, 124, "apt=120"),
+	}
+}
+
+func TestPayloadMap(t *testing.T) {
+	m := NewPayloadMap(publisherCodecs(), subscriberCodecs())
+
+	tests := []struct {
+		name      string
+		publisher uint8
+		want      uint8
+		ok        bool
+	}{
+		{"vp8", 96, 120, true},
+		{"rtx of vp8 follow its apt", 97, 124, true},
+		{"vp9 not negotiated", 98, 0, false},
+		{"rtx of codec not negotiated", 99, 0, false},
+		{"h264 match packetization-mode and profile", 102, 126, true},
+		{"rtx of h264", 103, 127, true},
+		{"h264 high profile ignore level", 104, 105, true},
+		{"unknown payload type", 111, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := m.Get(tt.publisher)
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Errorf("Get(%d) = %d, %v, want %d, %v", tt.publisher, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestPayloadMapFallbackMimeType(t *testing.T) {
+	publisher := []webrtc.RTPCodecParameters{
+		testCodec(webrtc.MimeTypeH264, 102, "packetization-mode=0;profile-level-id=42001f"),
+	}
+	subscriber := []webrtc.RTPCodecParameters{
+		testCodec(webrtc.MimeTypeH264, 108, "packetization-mode=1;profile-level-id=42001f"),
+	}
+	if got, ok := NewPayloadMap(publisher, subscriber).Get(102); !ok || got != 108 {
+		t.Errorf("Get(102) = %d, %v, want 108, true", got, ok)
+	}
+}
+
+// testWriter record packet written to a subscriber sender
+type testWriter struct {
+	headers  []rtp.Header
+	payloads [][]byte
+}
+
+func (w *testWriter) WriteRTP(header *rtp.Header, payload []byte) (int, error) {
+	w.headers = append(w.headers, *header)
+	w.payloads = append(w.payloads, append([]byte(nil), payload...))
+	return len(payload), nil
+}
+
+func (w *testWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func newTestTrack(t *testing.T, subscribers ...[]webrtc.RTPCodecParameters) (*rtpTrack, []*testWriter) {
+	t.Helper()
+	track, err := newRTPTrack(webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, "video", "stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writers := make([]*testWriter, 0, len(subscribers))
+	for i, codecs := range subscribers {
+		w := &testWriter{}
+		writers = append(writers, w)
+		track.bindings = append(track.bindings, &rtpBinding{
+			id:          string(rune('a' + i)),
+			ssrc:        webrtc.SSRC(1000 + i),
+			payloadType: codecs[0].PayloadType,
+			codecs:      codecs,
+			rtxSeq:      500,
+			writeStream: w,
+		})
+	}
+	track.setPublisher(publisherCodecs())
+	return track, writers
+}
+
+func TestRTPTrackWriteRTP(t *testing.T) {
+	withoutRTX := []webrtc.RTPCodecParameters{testCodec(webrtc.MimeTypeVP8, 100, "")}
+	track, writers := newTestTrack(t, subscriberCodecs(), withoutRTX)
+
+	if err := track.WriteRTP(&rtp.Packet{Header: rtp.Header{PayloadType: 96, SequenceNumber: 7, SSRC: 1}, Payload: []byte{1}}); err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []uint8{120, 100} {
+		w := writers[i]
+		if len(w.headers) != 1 {
+			t.Fatalf("subscriber %d got %d packet, want 1", i, len(w.headers))
+		}
+		if w.headers[0].PayloadType != want || w.headers[0].SSRC != uint32(1000+i) || w.headers[0].SequenceNumber != 7 {
+			t.Errorf("subscriber %d header = %+v, want payload type %d", i, w.headers[0], want)
+		}
+	}
+
+	// vp9 is dropped for every subscriber
+	if track.accept(98) {
+		t.Error("accept(98) = true, no subscriber negotiated vp9")
+	}
+	_ = track.WriteRTP(&rtp.Packet{Header: rtp.Header{PayloadType: 98}, Payload: []byte{1}})
+	for i, w := range writers {
+		if len(w.headers) != 1 {
+			t.Errorf("subscriber %d got packet of codec not negotiated", i)
+		}
+	}
+
+	// unknown publisher use payload type of bound codec
+	track.setPublisher(nil)
+	_ = track.WriteRTP(&rtp.Packet{Header: rtp.Header{PayloadType: 96}, Payload: []byte{1}})
+	if got := writers[0].headers[1].PayloadType; got != 97 {
+		t.Errorf("payload type without publisher = %d, want bound 97", got)
+	}
+}
+
+func TestRTPTrackWriteRTX(t *testing.T) {
+	withoutRTX := []webrtc.RTPCodecParameters{testCodec(webrtc.MimeTypeVP8, 100, "")}
+	track, writers := newTestTrack(t, subscriberCodecs(), withoutRTX)
+
+	packet := &rtp.Packet{Header: rtp.Header{PayloadType: 96, SequenceNumber: 4242, SSRC: 1}, Payload: []byte{0xaa, 0xbb}}
+	for i := 0; i < 2; i++ {
+		if err := track.WriteRTX(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i, w := range writers {
+		if len(w.headers) != 2 {
+			t.Fatalf("subscriber %d got %d retransmission, want 2", i, len(w.headers))
+		}
+	}
+
+	// subscriber negotiated rtx 124 for vp8 120
+	rtx := writers[0]
+	for i, header := range rtx.headers {
+		if header.SSRC != uint32(track.rtxSSRC) || header.PayloadType != 124 || header.SequenceNumber != uint16(500+i) {
+			t.Errorf("rtx header %d = %+v", i, header)
+		}
+		payload := rtx.payloads[i]
+		if len(payload) != 4 || binary.BigEndian.Uint16(payload) != 4242 || payload[2] != 0xaa || payload[3] != 0xbb {
+			t.Errorf("rtx payload %d = %x, want original sequence number prefix", i, payload)
+		}
+	}
+
+	// subscriber without rtx receive the packet again on primary ssrc
+	primary := writers[1]
+	for i, header := range primary.headers {
+		if header.SSRC != 1001 || header.PayloadType != 100 || header.SequenceNumber != 4242 || len(primary.payloads[i]) != 2 {
+			t.Errorf("retransmission %d = %+v %x", i, header, primary.payloads[i])
+		}
+	}
+}
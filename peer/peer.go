@@ -5,11 +5,13 @@ import (
 	"os"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/spgnk/rtc/errs"
 	"github.com/spgnk/rtc/utils"
 
 	"github.com/mitchellh/mapstructure"
+	"github.com/pion/interceptor/pkg/cc"
 	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v3"
 	"github.com/pion/webrtc/v3/pkg/media"
@@ -38,6 +40,17 @@ type Peer struct {
 	duplicated map[string]bool
 	pli        int // set PLI interval
 
+	estimator      cc.BandwidthEstimator // bandwidth estimator of peer down
+	bitrateHandler func(bitrate int)     // handle estimated bitrate change
+	remb           int                   // last REMB bitrate of peer down in bps
+
+	sdpMutex           sync.Mutex                             // queue sdp operation, one at a time
+	negotiationHandler func(offer *webrtc.SessionDescription) // receive offer of renegotiation
+	negotiationTimer   *time.Timer                            // debounce negotiation needed
+	negotiationGen     uint64                                 // generation of negotiationTimer, fired timer of older generation is ignored
+	pendingNegotiation bool                                   // renegotiate when signaling state is stable again
+	ignoreOffer        bool                                   // remote offer was ignored on glare
+
 	logger utils.Log // init logger
 }
 
@@ -60,8 +73,9 @@ func newPeerConnection(configs *Configs) *Peer {
 		duplicated:  make(map[string]bool),
 	}
 
-	if configs.Bitrate == nil {
-		br := 200
+	// nil bitrate is no REMB limit for publisher
+	if configs.Bitrate != nil {
+		br := *configs.Bitrate
 		p.bitrate = &br
 	}
 	return p
@@ -189,7 +203,9 @@ func (p *Peer) Init() (*webrtc.PeerConnection, error) {
 	}
 	p.setConn(conn)
 
-	tracks := NewTracks(conn)
+	tracks := NewTracks(conn, api)
+	tracks.setBitrateReport(p.setRemb)
+	tracks.setNegotiationNeeded(p.handleNegotiationNeeded)
 	p.tracks = tracks
 
 	return conn, nil
@@ -200,6 +216,7 @@ func (p *Peer) Close() {
 	if !p.checkClose() {
 		p.setClose(true)
 		p.setBitrate(nil)
+		p.stopNegotiationTimer()
 		if err := p.closeConn(); err != nil {
 			p.Error(err.Error(), nil)
 		}
@@ -278,13 +295,31 @@ func (p *Peer) AddSDP(values interface{}) error {
 	return nil
 }
 
-// AddOffer add client offer and return answer
+// AddOffer add client offer and return answer.
+// On glare, polite peer rollback its offer, impolite peer ignore the remote offer with ErrP007
 func (p *Peer) AddOffer(offer *webrtc.SessionDescription) error {
+	defer p.resumeNegotiation()
+	p.sdpMutex.Lock()
+	defer p.sdpMutex.Unlock()
+
 	conn := p.getConn()
 	if conn == nil {
 		return errs.ErrP002
 	}
 
+	if conn.SignalingState() != webrtc.SignalingStateStable {
+		if !p.isPolite() {
+			p.setIgnoreOffer(true)
+			return errs.ErrP007
+		}
+		// remote offer win, our change is negotiated again after answer
+		if err := conn.SetLocalDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeRollback}); err != nil {
+			return err
+		}
+		p.setPendingNegotiation(true)
+	}
+	p.setIgnoreOffer(false)
+
 	// set remote desc
 	err := conn.SetRemoteDescription(*offer)
 	if err != nil {
@@ -296,7 +331,7 @@ func (p *Peer) AddOffer(offer *webrtc.SessionDescription) error {
 		return err
 	}
 
-	err = p.CreateAnswer()
+	err = p.createAnswer()
 	if err != nil {
 		return err
 	}
@@ -306,6 +341,10 @@ func (p *Peer) AddOffer(offer *webrtc.SessionDescription) error {
 
 // AddAnswer add client answer and set remote desc
 func (p *Peer) AddAnswer(answer *webrtc.SessionDescription) error {
+	defer p.resumeNegotiation()
+	p.sdpMutex.Lock()
+	defer p.sdpMutex.Unlock()
+
 	conn := p.getConn()
 	if conn == nil {
 		return errs.ErrP002
@@ -344,6 +383,10 @@ func (p *Peer) AddICECandidate(icecandidate interface{}) error {
 
 	err := conn.AddICECandidate(*candidateInit)
 	if err != nil {
+		// candidate of ignored offer is expected to fail
+		if p.isIgnoreOffer() {
+			return nil
+		}
 		return err
 	}
 
@@ -358,6 +401,17 @@ func (p *Peer) AddVideoRTP(trackID, peerConnectionID *string, packet *rtp.Packet
 		return errs.ErrP0031
 	}
 
+	// codec not negotiated by subscriber is dropped, seq stay continuous
+	if !p.tracks.accept(trackID, track, packet) {
+		p.tracks.skip(trackID, packet)
+		return nil
+	}
+
+	// keep seq/timestamp continuous when source change
+	if !p.tracks.rewrite(trackID, packet) {
+		return nil
+	}
+
 	if err := p.writeRTP(packet, track); err != nil {
 		return err
 	}
@@ -379,6 +433,18 @@ func (p *Peer) AddAudioRTP(trackID, peerConnectionID *string, packet *rtp.Packet
 	if track == nil {
 		return errs.ErrP0032
 	}
+
+	// codec not negotiated by subscriber is dropped, seq stay continuous
+	if !p.tracks.accept(trackID, track, packet) {
+		p.tracks.skip(trackID, packet)
+		return nil
+	}
+
+	// keep seq/timestamp continuous when source change
+	if !p.tracks.rewrite(trackID, packet) {
+		return nil
+	}
+
 	if err := p.writeRTP(packet, track); err != nil {
 		return err
 	}
@@ -411,7 +477,7 @@ func getNodeLevel() int {
 
 // HandleVideoTrack handle all video track
 func (p *Peer) HandleVideoTrack(remoteTrack *webrtc.TrackRemote) {
-	// go p.modifyBitrate(remoteTrack)
+	go p.modifyBitrate(remoteTrack)
 	if getNodeLevel() == 0 {
 		go p.pictureLossIndication(remoteTrack)
 	}
@@ -422,6 +488,9 @@ func (p *Peer) HandleVideoTrack(remoteTrack *webrtc.TrackRemote) {
 
 // CreateOffer add offer
 func (p *Peer) CreateOffer(iceRestart bool) error {
+	p.sdpMutex.Lock()
+	defer p.sdpMutex.Unlock()
+
 	conn := p.getConn()
 	if conn == nil {
 		return errs.ErrP002
@@ -448,6 +517,12 @@ func (p *Peer) CreateOffer(iceRestart bool) error {
 
 // CreateAnswer add answer
 func (p *Peer) CreateAnswer() error {
+	p.sdpMutex.Lock()
+	defer p.sdpMutex.Unlock()
+	return p.createAnswer()
+}
+
+func (p *Peer) createAnswer() error {
 	conn := p.getConn()
 	if conn == nil {
 		return errs.ErrP002
@@ -477,7 +552,6 @@ func (p *Peer) AddAudioTrack(trackConfig *TrackConfig) error {
 
 // RemoveVideoTrack remove peering existing track
 func (p *Peer) RemoveVideoTrack(trackID *string) error {
-	var err error
 	conn := p.getConn()
 	if conn == nil {
 		return errs.ErrP002
@@ -489,26 +563,24 @@ func (p *Peer) RemoveVideoTrack(trackID *string) error {
 		return fmt.Errorf("RemoveVideoTrack %s ", errs.ErrP005.Error())
 	}
 
-	err = conn.RemoveTrack(sender)
-	if err != nil {
-		return err
-	}
-	err = p.tracks.removeLocalVideoTrack(trackID)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	// transceiver of track stop sending and is reused by next track
+	return p.tracks.removeLocalVideoTrack(trackID)
 }
 
-// GetAudioRTPTrack linter
+// GetAudioRTPTrack return track without payload type mapping, use AddAudioRTP to forward publisher packet
 func (p *Peer) GetAudioRTPTrack(trackID *string) *webrtc.TrackLocalStaticRTP {
-	return p.tracks.getAudioTrack(trackID)
+	if track := p.tracks.getAudioTrack(trackID); track != nil {
+		return track.TrackLocalStaticRTP
+	}
+	return nil
 }
 
-// GetVideoRTPTrack linter
+// GetVideoRTPTrack return track without payload type mapping, use AddVideoRTP to forward publisher packet
 func (p *Peer) GetVideoRTPTrack(trackID *string) *webrtc.TrackLocalStaticRTP {
-	return p.tracks.getVideoTrack(trackID)
+	if track := p.tracks.getVideoTrack(trackID); track != nil {
+		return track.TrackLocalStaticRTP
+	}
+	return nil
 }
 
 // ReplaceAudioTrack remove peering existing track
@@ -523,7 +595,6 @@ func (p *Peer) ReplaceVideoTrack(trackID, codec *string) error {
 
 // RemoveAudioTrack remove peering existing track
 func (p *Peer) RemoveAudioTrack(trackID *string) error {
-	var err error
 	conn := p.getConn()
 	if conn == nil {
 		return errs.ErrP002
@@ -535,16 +606,8 @@ func (p *Peer) RemoveAudioTrack(trackID *string) error {
 		return fmt.Errorf("RemoveAudioTrack %s ", errs.ErrP005.Error())
 	}
 
-	err = conn.RemoveTrack(sender)
-	if err != nil {
-		return err
-	}
-	err = p.tracks.removeLocalAudioTrack(trackID)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	// transceiver of track stop sending and is reused by next track
+	return p.tracks.removeLocalAudioTrack(trackID)
 }
 
 // AddVideoSample linter
@@ -588,9 +651,167 @@ func (p *Peer) IsFirstInit(trackID *string) string {
 	return p.tracks.getFirstInitTrack(trackID)
 }
 
-// SetPliInterval linter
+// SetCodecSource set handler return codecs of publisher feeding local track, use to map payload type of subscriber
+func (p *Peer) SetCodecSource(source func(trackID string) []webrtc.RTPCodecParameters) {
+	p.tracks.setCodecSource(source)
+}
+
+// SetPacketSource set handler return cached source packet to answer NACK of subscriber
+func (p *Peer) SetPacketSource(source func(trackID string, ssrc uint32, seq uint16) ([]byte, bool)) {
+	p.tracks.setPacketSource(source)
+}
+
+// SetKeyframeRequestHandler set handler receive PLI/FIR of subscriber with local trackID
+func (p *Peer) SetKeyframeRequestHandler(handler func(trackID string)) {
+	p.tracks.setKeyframeRequest(handler)
+}
+
+// SkipVideoRTP packet was filtered by svc layer, keep seq continuous without write
+func (p *Peer) SkipVideoRTP(trackID *string, packet *rtp.Packet) {
+	p.tracks.skip(trackID, packet)
+}
+
+// SwitchSource mark local track is switching to a new source
+// late packet of the old source will be dropped
+func (p *Peer) SwitchSource(trackID *string) {
+	p.tracks.switchSource(trackID)
+}
+
+// ResyncSource keep seq/timestamp continuous when forwarding to local track is resumed
+func (p *Peer) ResyncSource(trackID *string) {
+	p.tracks.resync(trackID)
+}
+
+// GetTrackSource return source (camera/screen/custom) of local track trackID, empty if not set
+func (p *Peer) GetTrackSource(trackID *string) string {
+	return p.tracks.getSource(trackID)
+}
+
+// SetPliInterval set periodic PLI interval in second.
+// 0 is default 30s, negative value disable periodic PLI
 func (p *Peer) SetPliInterval(interval int) {
 	p.mutex.Lock()
 	defer p.mutex.Unlock()
 	p.pli = interval
 }
+
+// EstimatedBitrate return estimated bandwidth of peer down in bps, 0 if not available
+func (p *Peer) EstimatedBitrate() int {
+	if estimator := p.getEstimator(); estimator != nil {
+		return estimator.GetTargetBitrate()
+	}
+	return 0
+}
+
+// SetBitrateHandler set handler receive new estimated bitrate in bps
+func (p *Peer) SetBitrateHandler(handler func(bitrate int)) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.bitrateHandler = handler
+}
+
+// ReceiverBitrate return last REMB bitrate of peer down in bps, 0 if not received
+func (p *Peer) ReceiverBitrate() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.remb
+}
+
+// SetBitrate set REMB bitrate (kbps) send to peer up, 0 is no limit
+func (p *Peer) SetBitrate(bitrate int) {
+	if bitrate <= 0 {
+		p.setBitrate(nil)
+		return
+	}
+	p.setBitrate(&bitrate)
+}
+
+// GetHeaderExtensionID return negotiated id of header extension uri of remoteTrack, 0 if not negotiated
+func (p *Peer) GetHeaderExtensionID(remoteTrack *webrtc.TrackRemote, uri string) uint8 {
+	conn := p.getConn()
+	if conn == nil || remoteTrack == nil {
+		return 0
+	}
+	for _, receiver := range conn.GetReceivers() {
+		for _, track := range receiver.Tracks() {
+			if track != remoteTrack {
+				continue
+			}
+			for _, ext := range receiver.GetParameters().HeaderExtensions {
+				if ext.URI == uri {
+					return uint8(ext.ID)
+				}
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// GetRemoteCodecs return codecs negotiated by transceiver receiving remoteTrack, include rtx and fec. nil if not found
+func (p *Peer) GetRemoteCodecs(remoteTrack *webrtc.TrackRemote) []webrtc.RTPCodecParameters {
+	conn := p.getConn()
+	if conn == nil || remoteTrack == nil {
+		return nil
+	}
+	for _, receiver := range conn.GetReceivers() {
+		for _, track := range receiver.Tracks() {
+			if track == remoteTrack {
+				return receiver.GetParameters().Codecs
+			}
+		}
+	}
+	return nil
+}
+
+// GetMid return mid of transceiver receiving remoteTrack, empty if not found
+func (p *Peer) GetMid(remoteTrack *webrtc.TrackRemote) string {
+	conn := p.getConn()
+	if conn == nil || remoteTrack == nil {
+		return ""
+	}
+	for _, trans := range conn.GetTransceivers() {
+		receiver := trans.Receiver()
+		if receiver == nil {
+			continue
+		}
+		for _, track := range receiver.Tracks() {
+			if track == remoteTrack {
+				return trans.Mid()
+			}
+		}
+	}
+	return ""
+}
+
+// StopReceiving stop transceiver receiving remoteTrack, remote side see it inactive on next negotiation
+func (p *Peer) StopReceiving(remoteTrack *webrtc.TrackRemote) error {
+	conn := p.getConn()
+	if conn == nil || remoteTrack == nil {
+		return nil
+	}
+	for _, trans := range conn.GetTransceivers() {
+		receiver := trans.Receiver()
+		if receiver == nil {
+			continue
+		}
+		for _, track := range receiver.Tracks() {
+			if track == remoteTrack {
+				return trans.Stop()
+			}
+		}
+	}
+	return nil
+}
+
+// GatheringCompletePromise return channel closed when ICE gathering is complete.
+// Must be called before set local description, use when client does not trickle ICE
+func (p *Peer) GatheringCompletePromise() <-chan struct{} {
+	conn := p.getConn()
+	if conn == nil {
+		done := make(chan struct{})
+		close(done)
+		return done
+	}
+	return webrtc.GatheringCompletePromise(conn)
+}
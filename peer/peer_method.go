@@ -7,6 +7,7 @@ import (
 	"github.com/spgnk/rtc/errs"
 	"github.com/spgnk/rtc/utils"
 
+	"github.com/pion/interceptor/pkg/cc"
 	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v3"
@@ -31,7 +32,7 @@ func (p *Peer) setClose(state bool) {
 	p.isClosed = state
 }
 
-func (p *Peer) writeRTP(packet *rtp.Packet, track *webrtc.TrackLocalStaticRTP) error {
+func (p *Peer) writeRTP(packet *rtp.Packet, track *rtpTrack) error {
 	return track.WriteRTP(packet)
 }
 
@@ -97,7 +98,15 @@ func (p *Peer) getLocalDescription() (*webrtc.SessionDescription, error) {
 	if conn == nil {
 		return nil, errs.ErrP002
 	}
-	return conn.LocalDescription(), nil
+	return p.signalRTX(conn.LocalDescription()), nil
+}
+
+// signalRTX add rtx ssrc of local video track to desc sent to remote
+func (p *Peer) signalRTX(desc *webrtc.SessionDescription) *webrtc.SessionDescription {
+	if p.tracks == nil {
+		return desc
+	}
+	return p.tracks.signalRTX(desc)
 }
 
 func (p *Peer) getIceCache() *utils.AdvanceMap {
@@ -148,18 +157,24 @@ func (p *Peer) clearIceCache() {
 // Use this only for video not audio track
 func (p *Peer) modifyBitrate(remoteTrack *webrtc.TrackRemote) {
 	ticker := time.NewTicker(time.Millisecond * 500)
+	defer ticker.Stop()
 	for range ticker.C {
-		bitrate := p.getBitrate()
-		if p.checkClose() || bitrate == nil {
+		if p.checkClose() {
 			return
 		}
 
+		// no limit, let publisher decide
+		bitrate := p.getBitrate()
+		if bitrate == nil {
+			continue
+		}
+
 		numbers := *bitrate * 1024
 		if conn := p.getConn(); conn != nil {
 			errSend := conn.WriteRTCP([]rtcp.Packet{&rtcp.ReceiverEstimatedMaximumBitrate{
 				SenderSSRC: uint32(remoteTrack.SSRC()),
 				Bitrate:    float32(numbers),
-				// SSRCs:      []uint32{rand.Uint32()},
+				SSRCs:      []uint32{uint32(remoteTrack.SSRC())},
 			}})
 
 			if errSend != nil {
@@ -173,8 +188,11 @@ func (p *Peer) modifyBitrate(remoteTrack *webrtc.TrackRemote) {
 // PictureLossIndication packet informs the encoder about the loss of an undefined amount of coded video data belonging to one or more pictures
 func (p *Peer) pictureLossIndication(remoteTrack *webrtc.TrackRemote) {
 	interval := 30
-	if p.pli != 0 {
-		interval = p.pli
+	if pli := p.getPliInterval(); pli < 0 {
+		// periodic pli is disabled, keyframe is requested by subscriber
+		return
+	} else if pli != 0 {
+		interval = pli
 	}
 
 	ticker := time.NewTicker(time.Second * time.Duration(interval))
@@ -206,14 +224,19 @@ func (p *Peer) SendPictureLossIndication() {
 	if remoteTrack == nil {
 		return
 	}
+	p.SendPictureLossIndicationTo(uint32(remoteTrack.SSRC()))
+}
+
+// SendPictureLossIndicationTo send PLI to specific remote track ssrc
+func (p *Peer) SendPictureLossIndicationTo(ssrc uint32) {
 	conn := p.getConn()
 	if conn == nil {
 		return
 	}
 	errSend := conn.WriteRTCP([]rtcp.Packet{
-		&rtcp.PictureLossIndication{MediaSSRC: uint32(remoteTrack.SSRC())},
-		// &rtcp.SliceLossIndication{MediaSSRC: uint32(remoteTrack.SSRC())},
-		// &rtcp.RapidResynchronizationRequest{SenderSSRC: uint32(remoteTrack.SSRC()), MediaSSRC: uint32(remoteTrack.SSRC())},
+		&rtcp.PictureLossIndication{MediaSSRC: ssrc},
+		// &rtcp.SliceLossIndication{MediaSSRC: ssrc},
+		// &rtcp.RapidResynchronizationRequest{SenderSSRC: ssrc, MediaSSRC: ssrc},
 	})
 
 	if errSend != nil {
@@ -222,6 +245,12 @@ func (p *Peer) SendPictureLossIndication() {
 	}
 }
 
+func (p *Peer) getPliInterval() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.pli
+}
+
 func (p *Peer) getRemoteTrack() *webrtc.TrackRemote {
 	p.mutex.RLock()
 	defer p.mutex.RUnlock()
@@ -269,3 +298,38 @@ func (p *Peer) AddDuplicated(t string, element bool) {
 func (p *Peer) SetLogger(log utils.Log) {
 	p.logger = log
 }
+
+// setEstimator is called when peer connection is created
+func (p *Peer) setEstimator(estimator cc.BandwidthEstimator) {
+	p.mutex.Lock()
+	p.estimator = estimator
+	p.mutex.Unlock()
+	estimator.OnTargetBitrateChange(p.handleBitrateChange)
+}
+
+func (p *Peer) getEstimator() cc.BandwidthEstimator {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.estimator
+}
+
+func (p *Peer) getBitrateHandler() func(bitrate int) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.bitrateHandler
+}
+
+func (p *Peer) handleBitrateChange(bitrate int) {
+	if p.checkClose() {
+		return
+	}
+	if handler := p.getBitrateHandler(); handler != nil {
+		handler(bitrate)
+	}
+}
+
+func (p *Peer) setRemb(bitrate int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.remb = bitrate
+}
@@ -185,11 +185,13 @@ func (p *Peers) AddConnection(
 		}
 	})
 
-	// conn.OnNegotiationNeeded(func() {
-	// 	if handleOnNegotiationNeeded != nil {
-	// 		handleOnNegotiationNeeded(p.getSignalID(), configs.PeerConnectionID, peer.cookieID)
-	// 	}
-	// })
+	// handler get the offer of renegotiation by GetLocalDescription
+	if handleOnNegotiationNeeded != nil {
+		peer.SetNegotiationHandler(func(_ *webrtc.SessionDescription) {
+			handleOnNegotiationNeeded(p.signalID, configs.PeerConnectionID, peer.getCookieID())
+		})
+	}
+	conn.OnNegotiationNeeded(peer.handleNegotiationNeeded)
 
 	p.setPeer(configs.PeerConnectionID, peer)
 	return peer, nil
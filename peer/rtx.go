@@ -0,0 +1,153 @@
+package peer
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+)
+
+// RTX retransmission (RFC 4588) of local video track.
+// pion v3 sender does not signal rtx ssrc and refuse a modified sdp in SetLocalDescription,
+// so the rtx ssrc-group is added to local description sent to remote
+
+// signalRTX return desc with rtx ssrc of every local video track, nil desc return nil
+func (t *LocalTracks) signalRTX(desc *webrtc.SessionDescription) *webrtc.SessionDescription {
+	if desc == nil {
+		return nil
+	}
+	groups := t.getRTXGroups()
+	if len(groups) == 0 {
+		return desc
+	}
+	return &webrtc.SessionDescription{
+		Type: desc.Type,
+		SDP:  addRTXGroups(desc.SDP, groups),
+	}
+}
+
+// getRTXGroups return primary ssrc - rtx ssrc of local video track
+func (t *LocalTracks) getRTXGroups() map[uint32]uint32 {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	groups := make(map[uint32]uint32)
+	for trackID, sender := range t.videoSenders {
+		track, ok := t.videoTracks[trackID].(*rtpTrack)
+		if !ok || sender == nil {
+			continue
+		}
+		if encodings := sender.GetParameters().Encodings; len(encodings) > 0 && encodings[0].SSRC != 0 {
+			groups[uint32(encodings[0].SSRC)] = uint32(track.rtxSSRC)
+		}
+	}
+	return groups
+}
+
+// addRTXGroups add ssrc-group FID to video media section sending a primary ssrc of groups and offering rtx
+func addRTXGroups(sdp string, groups map[uint32]uint32) string {
+	lines := strings.Split(sdp, "\r\n")
+	result := make([]string, 0, len(lines)+3*len(groups))
+	section := make([]string, 0)
+	for _, line := range lines {
+		if strings.HasPrefix(line, "m=") {
+			result = append(result, addRTXGroup(section, groups)...)
+			section = make([]string, 0)
+		}
+		section = append(section, line)
+	}
+	return strings.Join(append(result, addRTXGroup(section, groups)...), "\r\n")
+}
+
+func addRTXGroup(section []string, groups map[uint32]uint32) []string {
+	if len(section) == 0 || !strings.HasPrefix(section[0], "m=video") {
+		return section
+	}
+
+	hasRTX := false
+	var primary, rtx uint32
+	var attrs []string
+	first, last := -1, -1
+	for i, line := range section {
+		switch {
+		case strings.HasPrefix(line, "a=ssrc-group:FID"):
+			// already signaled
+			return section
+		case strings.HasPrefix(line, "a=rtpmap:") && strings.Contains(line, " rtx/"):
+			hasRTX = true
+		case strings.HasPrefix(line, "a=ssrc:"):
+			pair := strings.SplitN(strings.TrimPrefix(line, "a=ssrc:"), " ", 2)
+			ssrc, err := strconv.ParseUint(pair[0], 10, 32)
+			if err != nil || len(pair) != 2 {
+				continue
+			}
+			if r, ok := groups[uint32(ssrc)]; ok {
+				primary, rtx = uint32(ssrc), r
+				attrs = append(attrs, pair[1])
+				if first < 0 {
+					first = i
+				}
+				last = i
+			}
+		}
+	}
+	if !hasRTX || last < 0 {
+		return section
+	}
+
+	// group is put before ssrc lines like browser do, parser of pion need it first
+	temp := make([]string, 0, len(section)+len(attrs)+1)
+	temp = append(temp, section[:first]...)
+	temp = append(temp, fmt.Sprintf("a=ssrc-group:FID %d %d", primary, rtx))
+	temp = append(temp, section[first:last+1]...)
+	for _, attr := range attrs {
+		temp = append(temp, fmt.Sprintf("a=ssrc:%d %s", rtx, attr))
+	}
+	return append(temp, section[last+1:]...)
+}
+
+// rtxPayloadType return rtx payload type negotiated by subscriber for payloadType
+func (b *rtpBinding) rtxPayloadType(payloadType uint8) (uint8, bool) {
+	for i := range b.codecs {
+		if apt, ok := getApt(&b.codecs[i]); ok && isRTX(&b.codecs[i]) && apt == payloadType {
+			return uint8(b.codecs[i].PayloadType), true
+		}
+	}
+	return 0, false
+}
+
+// WriteRTX retransmit p on rtx ssrc with original sequence number prefix to subscriber negotiated rtx.
+// Subscriber without rtx receive p again on primary ssrc
+func (t *rtpTrack) WriteRTX(p *rtp.Packet) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var err error
+	for _, b := range t.bindings {
+		payloadType, ok := b.mapPayloadType(p.PayloadType)
+		if !ok {
+			continue
+		}
+
+		header := p.Header
+		header.Padding = false
+		header.SSRC = uint32(b.ssrc)
+		header.PayloadType = payloadType
+		payload := p.Payload
+		if rtxType, ok := b.rtxPayloadType(payloadType); ok {
+			payload = make([]byte, 2+len(p.Payload))
+			binary.BigEndian.PutUint16(payload, p.SequenceNumber)
+			copy(payload[2:], p.Payload)
+			header.SSRC = uint32(t.rtxSSRC)
+			header.PayloadType = rtxType
+			header.SequenceNumber = b.rtxSeq
+			b.rtxSeq++
+		}
+		if _, e := b.writeStream.WriteRTP(&header, payload); e != nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -7,29 +7,52 @@ import (
 	"github.com/spgnk/rtc/errs"
 	"github.com/spgnk/rtc/utils"
 
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v3"
 )
 
+const (
+	videoClockRate = 90000
+	audioClockRate = 48000
+)
+
 // LocalTracks control sending track
 type LocalTracks struct {
 	// mode         *string // mode to set default codec or modify
 	conn           *webrtc.PeerConnection
+	api            *webrtc.API // create sender of reused transceiver
 	trans          map[string]*webrtc.RTPTransceiver
+	idleTrans      []*webrtc.RTPTransceiver // transceiver of removed track, reused by next track of the same kind
 	videoTracks    map[string]webrtc.TrackLocal
 	audioTracks    map[string]webrtc.TrackLocal
 	videoSenders   map[string]*webrtc.RTPSender
 	audioSenders   map[string]*webrtc.RTPSender
 	receiveData    map[string]bool // save to trackID - state
 	firstInitTrack map[string]string
-	mutex          sync.RWMutex
+	rewriters      map[string]*utils.Rewriter // save trackID - seq/timestamp rewriter
+	sources        map[string]string          // save trackID - source of local track
+	// packetSource return cached source packet to answer NACK
+	packetSource func(trackID string, ssrc uint32, seq uint16) ([]byte, bool)
+	// keyframeRequest forward PLI/FIR of subscriber to publisher
+	keyframeRequest func(trackID string)
+	// bitrateReport receive REMB bitrate of subscriber
+	bitrateReport func(bitrate int)
+	// codecSource return codecs of publisher feeding trackID, nil if unknown
+	codecSource func(trackID string) []webrtc.RTPCodecParameters
+	// negotiationNeeded is called when a transceiver is reused, pion only fire it for new transceiver
+	negotiationNeeded func()
+	mutex             sync.RWMutex
 }
 
 // NewTracks linter
 func NewTracks(
 	conn *webrtc.PeerConnection,
+	api *webrtc.API,
 ) *LocalTracks {
 	l := &LocalTracks{
 		conn:           conn,
+		api:            api,
 		trans:          make(map[string]*webrtc.RTPTransceiver),
 		videoTracks:    make(map[string]webrtc.TrackLocal),
 		audioTracks:    make(map[string]webrtc.TrackLocal),
@@ -37,27 +60,26 @@ func NewTracks(
 		audioSenders:   make(map[string]*webrtc.RTPSender),
 		receiveData:    make(map[string]bool),
 		firstInitTrack: make(map[string]string),
+		rewriters:      make(map[string]*utils.Rewriter),
+		sources:        make(map[string]string),
 	}
 
 	return l
 }
 
-// setCodecPreferences this only support for video because audio was fixed opus
+// setCodecPreferences set codec of transceiver sending trackConfig.trackID, must be called before offer/answer.
+// nil payLoadType use payload type of media engine
 func (t *LocalTracks) setCodecPreferences(payLoadType *int, trackConfig *TrackConfig) error {
-	if payLoadType != nil {
-		trans := t.getTrans(trackConfig.trackID)
-		if trans == nil {
-			return errs.ErrP006
-		}
-
-		err := trans.SetCodecPreferences(trackConfig.GetRTPCodecCapability(payLoadType))
-		if err != nil {
-			return err
-		}
+	trans := t.getTrans(trackConfig.trackID)
+	if trans == nil {
+		return fmt.Errorf("%s %s", *trackConfig.trackID, errs.ErrP006.Error())
+	}
 
-		return nil
+	payload := 0
+	if payLoadType != nil {
+		payload = *payLoadType
 	}
-	return fmt.Errorf("payLoadType is nil")
+	return trans.SetCodecPreferences(trackConfig.GetRTPCodecCapability(&payload))
 }
 
 // InitLocalTrack nil input payLoadType it mean get default
@@ -68,6 +90,10 @@ func (t *LocalTracks) createLocalVideo(trackConfig *TrackConfig) error {
 
 	var err error
 	if trackConfig.kind != nil && *trackConfig.kind == utils.SampleTrackType {
+		// pion has no hevc payloader, hevc is only forwarded as rtp
+		if trackConfig.codec == utils.ModeH265 {
+			return fmt.Errorf("%s %s", *trackConfig.trackID, errs.ErrP008.Error())
+		}
 		videoTrack, err = t._createTrackSample(trackConfig.trackID, &trackConfig.codec)
 		if err != nil {
 			return err
@@ -81,30 +107,28 @@ func (t *LocalTracks) createLocalVideo(trackConfig *TrackConfig) error {
 
 	// set track
 	t.setVideoTracks(trackConfig.trackID, videoTrack)
+	t.setRewriter(trackConfig.trackID, utils.NewRewriter(videoClockRate))
+	t.setSource(trackConfig.trackID, trackConfig.source)
 
-	// trans, err := t._initTransceiver(videoTrack, trackConfig.role)
-	// if err != nil {
-	// 	return err
-	// }
+	// release transceiver of old track
+	t.deleteVideoSender(trackConfig.trackID)
+	if err := t.releaseTrans(trackConfig.trackID); err != nil {
+		return err
+	}
 
-	sender, err := t.conn.AddTrack(videoTrack)
+	trans, err := t.initTransceiver(videoTrack, trackConfig)
 	if err != nil {
 		return err
 	}
-
-	// remove sender
-	t.deleteVideoSender(trackConfig.trackID)
-
-	// remove transceiver
-	t.closeTrans(trackConfig.trackID)
+	sender := trans.Sender()
 
 	// save sender video
 	t.addVideoSender(trackConfig.trackID, sender)
 
 	// save transceiver
-	// t.setTrans(trackConfig.trackID, trans)
+	t.setTrans(trackConfig.trackID, trans)
 
-	go t._processRTCP(sender)
+	go t._processRTCP(*trackConfig.trackID, sender)
 
 	// logs.Debug("Local video was created with config ")
 	// if os.Getenv("DEBUG") == "1" {
@@ -140,35 +164,33 @@ func (t *LocalTracks) createLocalAudio(trackConfig *TrackConfig) error {
 	}
 	// set track
 	t.setAudioTracks(trackConfig.trackID, audioTrack)
+	t.setRewriter(trackConfig.trackID, utils.NewRewriter(audioClockRate))
+	t.setSource(trackConfig.trackID, trackConfig.source)
 
-	// trans, err := t._initTransceiver(audioTrack, trackConfig.role)
-	// if err != nil {
-	// 	return err
-	// }
+	// release transceiver of old track
+	t.deleteAudioSender(trackConfig.trackID)
+	if err := t.releaseTrans(trackConfig.trackID); err != nil {
+		return err
+	}
 
-	sender, err := t.conn.AddTrack(audioTrack)
+	trans, err := t.initTransceiver(audioTrack, trackConfig)
 	if err != nil {
 		return err
 	}
+	sender := trans.Sender()
 
-	// remove sender
-	t.deleteAudioSender(trackConfig.trackID)
-
-	// remove transceiver
-	// t.closeTrans(trackConfig.trackID)
-
-	// save sender video
+	// save sender audio
 	t.addAudioSender(trackConfig.trackID, sender)
 
 	// save transceiver
-	// t.setTrans(trackConfig.trackID, trans)
+	t.setTrans(trackConfig.trackID, trans)
 
 	// logs.Debug("Local audio was created with config ")
 	// if os.Getenv("DEBUG") == "1" {
 	// 	spew.Dump(trackConfig)
 	// }
 
-	go t._processRTCP(sender)
+	go t._processRTCP(*trackConfig.trackID, sender)
 
 	// set first init
 	if t.getFirstInitTrack(trackConfig.trackID) == "" {
@@ -177,15 +199,139 @@ func (t *LocalTracks) createLocalAudio(trackConfig *TrackConfig) error {
 	return nil
 }
 
-func (t *LocalTracks) _processRTCP(rtpSender *webrtc.RTPSender) {
-	rtcpBuf := make([]byte, 1500)
+func (t *LocalTracks) _processRTCP(trackID string, rtpSender *webrtc.RTPSender) {
 	for {
-		if _, _, rtcpErr := rtpSender.Read(rtcpBuf); rtcpErr != nil {
+		pkts, _, rtcpErr := rtpSender.ReadRTCP()
+		if rtcpErr != nil {
 			return
 		}
+		for _, pkt := range pkts {
+			switch pkt := pkt.(type) {
+			case *rtcp.TransportLayerNack:
+				t.handleNack(&trackID, pkt)
+			case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+				if handler := t.getKeyframeRequest(); handler != nil {
+					handler(trackID)
+				}
+			case *rtcp.ReceiverEstimatedMaximumBitrate:
+				if handler := t.getBitrateReport(); handler != nil {
+					handler(int(pkt.Bitrate))
+				}
+			}
+		}
 	}
 }
 
+// handleNack retransmit lost packet from cache, on rtx ssrc if subscriber negotiated rtx
+func (t *LocalTracks) handleNack(trackID *string, nack *rtcp.TransportLayerNack) {
+	source := t.getPacketSource()
+	r := t.getRewriter(trackID)
+	if source == nil || r == nil {
+		return
+	}
+
+	track := t.getVideoTrack(trackID)
+	if track == nil {
+		track = t.getAudioTrack(trackID)
+	}
+	if track == nil {
+		return
+	}
+	t.syncPublisher(trackID, track)
+
+	for _, pair := range nack.Nacks {
+		for _, seq := range pair.PacketList() {
+			h, ok := r.Lookup(seq)
+			if !ok {
+				continue
+			}
+			data, ok := source(*trackID, h.SSRC, h.SrcSeq)
+			if !ok {
+				continue
+			}
+			pkg := &rtp.Packet{}
+			if err := pkg.Unmarshal(data); err != nil {
+				continue
+			}
+			pkg.SequenceNumber = h.OutSeq
+			pkg.Timestamp += h.TSOffset
+			pkg.Marker = h.Marker
+			if !track.accept(pkg.PayloadType) {
+				continue
+			}
+			if err := track.WriteRTX(pkg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (t *LocalTracks) setKeyframeRequest(handler func(trackID string)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.keyframeRequest = handler
+}
+
+func (t *LocalTracks) getKeyframeRequest() func(trackID string) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.keyframeRequest
+}
+
+func (t *LocalTracks) setBitrateReport(handler func(bitrate int)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.bitrateReport = handler
+}
+
+func (t *LocalTracks) getBitrateReport() func(bitrate int) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.bitrateReport
+}
+
+func (t *LocalTracks) setCodecSource(source func(trackID string) []webrtc.RTPCodecParameters) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.codecSource = source
+}
+
+func (t *LocalTracks) getCodecSource() func(trackID string) []webrtc.RTPCodecParameters {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.codecSource
+}
+
+// syncPublisher get codecs of publisher feeding trackID once, until source of trackID change
+func (t *LocalTracks) syncPublisher(trackID *string, track *rtpTrack) {
+	if track.hasPublisher() {
+		return
+	}
+	if source := t.getCodecSource(); source != nil {
+		if codecs := source(*trackID); len(codecs) > 0 {
+			track.setPublisher(codecs)
+		}
+	}
+}
+
+// accept map payload type of packet for subscriber, return false if subscriber has not negotiated its codec
+func (t *LocalTracks) accept(trackID *string, track *rtpTrack, packet *rtp.Packet) bool {
+	t.syncPublisher(trackID, track)
+	return track.accept(packet.PayloadType)
+}
+
+func (t *LocalTracks) setPacketSource(source func(trackID string, ssrc uint32, seq uint16) ([]byte, bool)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.packetSource = source
+}
+
+func (t *LocalTracks) getPacketSource() func(trackID string, ssrc uint32, seq uint16) ([]byte, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.packetSource
+}
+
 func (t *LocalTracks) removeLocalVideoTrack(trackID *string) error {
 	// delete sender
 	t.deleteVideoSender(trackID)
@@ -196,21 +342,12 @@ func (t *LocalTracks) removeLocalVideoTrack(trackID *string) error {
 	// remove video receive track
 	t.deleteReceiveData(trackID)
 
-	// // get trans
-	// trans := t.getTrans(trackID)
-	// if trans == nil {
-	// 	return fmt.Errorf("%s transceiver is nil", *trackID)
-	// }
-
-	// // delete trans
-	// defer t.deleteTrans(trackID)
+	// remove rewriter
+	t.deleteRewriter(trackID)
+	t.deleteSource(trackID)
 
-	// // close trans
-	// err := trans.Stop()
-	// if err != nil {
-	// 	return err
-	// }
-	return nil
+	// keep transceiver for next track
+	return t.releaseTrans(trackID)
 }
 
 func (t *LocalTracks) removeLocalAudioTrack(trackID *string) error {
@@ -223,26 +360,17 @@ func (t *LocalTracks) removeLocalAudioTrack(trackID *string) error {
 	// remove audio receive track
 	t.deleteReceiveData(trackID)
 
-	// // get trans
-	// trans := t.getTrans(trackID)
-	// if trans == nil {
-	// 	return fmt.Errorf("%s transceiver is nil", *trackID)
-	// }
-
-	// // delete trans
-	// defer t.deleteTrans(trackID)
+	// remove rewriter
+	t.deleteRewriter(trackID)
+	t.deleteSource(trackID)
 
-	// // close trans
-	// err := trans.Stop()
-	// if err != nil {
-	// 	return err
-	// }
-	return nil
+	// keep transceiver for next track
+	return t.releaseTrans(trackID)
 }
 
 // CreateTrack linter
-func (t *LocalTracks) _createTrack(id, codec *string) (*webrtc.TrackLocalStaticRTP, error) {
-	return webrtc.NewTrackLocalStaticRTP(
+func (t *LocalTracks) _createTrack(id, codec *string) (*rtpTrack, error) {
+	return newRTPTrack(
 		webrtc.RTPCodecCapability{MimeType: utils.GetCodec(codec)},
 		*id,
 		*id,
@@ -317,12 +445,12 @@ func (t *LocalTracks) deleteVideoTracks(id *string) {
 	delete(t.videoTracks, *id)
 }
 
-func (t *LocalTracks) getVideoTrack(trackID *string) *webrtc.TrackLocalStaticRTP {
-	var result *webrtc.TrackLocalStaticRTP
+func (t *LocalTracks) getVideoTrack(trackID *string) *rtpTrack {
+	var result *rtpTrack
 	t.mutex.RLock()
 	defer t.mutex.RUnlock()
 	if track := t.videoTracks[*trackID]; track != nil {
-		t, ok := track.(*webrtc.TrackLocalStaticRTP)
+		t, ok := track.(*rtpTrack)
 		if ok {
 			result = t
 		}
@@ -330,12 +458,12 @@ func (t *LocalTracks) getVideoTrack(trackID *string) *webrtc.TrackLocalStaticRTP
 	return result
 }
 
-func (t *LocalTracks) getAudioTrack(trackID *string) *webrtc.TrackLocalStaticRTP {
-	var result *webrtc.TrackLocalStaticRTP
+func (t *LocalTracks) getAudioTrack(trackID *string) *rtpTrack {
+	var result *rtpTrack
 	t.mutex.RLock()
 	defer t.mutex.RUnlock()
 	if track := t.audioTracks[*trackID]; track != nil {
-		t, ok := track.(*webrtc.TrackLocalStaticRTP)
+		t, ok := track.(*rtpTrack)
 		if ok {
 			result = t
 		}
@@ -343,42 +471,82 @@ func (t *LocalTracks) getAudioTrack(trackID *string) *webrtc.TrackLocalStaticRTP
 	return result
 }
 
-// initTransceiver init peer transceiver
-func (t *LocalTracks) _initTransceiver(track webrtc.TrackLocal, role *string) (*webrtc.RTPTransceiver, error) {
-	var dir webrtc.RTPTransceiverDirection
+// initTransceiver send track on an idle transceiver of the same kind and direction, or on a new transceiver
+func (t *LocalTracks) initTransceiver(track webrtc.TrackLocal, trackConfig *TrackConfig) (*webrtc.RTPTransceiver, error) {
+	direction := trackConfig.GetDirection()
+	if trans := t.popIdleTrans(track.Kind(), direction); trans != nil {
+		sender, err := t.api.NewRTPSender(track, t.conn.SCTP().Transport())
+		if err != nil {
+			return nil, err
+		}
+		// direction is set back to sendonly/sendrecv by pion
+		if err := trans.SetSender(sender, track); err != nil {
+			_ = sender.Stop()
+			return nil, err
+		}
+		if handler := t.getNegotiationNeeded(); handler != nil {
+			handler()
+		}
+		return trans, nil
+	}
+
+	return t.conn.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{Direction: direction})
+}
 
-	switch *role {
-	case "up":
-		dir = webrtc.RTPTransceiverDirectionSendonly
-	case "down":
-		dir = webrtc.RTPTransceiverDirectionSendrecv
-	default:
-		dir = webrtc.RTPTransceiverDirectionSendrecv
+// releaseTrans stop sending on transceiver of trackID, transceiver is kept idle in the session for reuse
+func (t *LocalTracks) releaseTrans(trackID *string) error {
+	trans := t.getTrans(trackID)
+	if trans == nil {
+		return nil
 	}
+	t.deleteTrans(trackID)
 
-	trans, err := t.conn.AddTransceiverFromTrack(track, webrtc.RTPTransceiverInit{Direction: dir})
-	if err != nil {
-		return nil, err
+	if sender := trans.Sender(); sender != nil {
+		if err := t.conn.RemoveTrack(sender); err != nil {
+			return err
+		}
 	}
+	t.addIdleTrans(trans)
+	return nil
+}
 
-	// trans.SetCodecPreferences(codecs []webrtc.RTPCodecParameters
-	return trans, nil
+func (t *LocalTracks) addIdleTrans(trans *webrtc.RTPTransceiver) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.idleTrans = append(t.idleTrans, trans)
 }
 
-func (t *LocalTracks) closeTrans(trackID *string) error {
-	tran := t.getTrans(trackID)
-	if tran == nil {
-		return fmt.Errorf(*trackID, errs.ErrP006.Error())
+// popIdleTrans return nil if no idle transceiver of kind was sending with direction
+func (t *LocalTracks) popIdleTrans(kind webrtc.RTPCodecType, direction webrtc.RTPTransceiverDirection) *webrtc.RTPTransceiver {
+	// removing track turn sendonly to inactive and sendrecv to recvonly
+	idle := webrtc.RTPTransceiverDirectionInactive
+	if direction == webrtc.RTPTransceiverDirectionSendrecv {
+		idle = webrtc.RTPTransceiverDirectionRecvonly
 	}
 
-	err := tran.Stop()
-	if err != nil {
-		return err
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for i, trans := range t.idleTrans {
+		if trans.Kind() == kind && trans.Direction() == idle && trans.Sender() == nil {
+			t.idleTrans = append(t.idleTrans[:i], t.idleTrans[i+1:]...)
+			return trans
+		}
 	}
-
 	return nil
 }
 
+func (t *LocalTracks) setNegotiationNeeded(handler func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.negotiationNeeded = handler
+}
+
+func (t *LocalTracks) getNegotiationNeeded() func() {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.negotiationNeeded
+}
+
 func (t *LocalTracks) deleteTrans(trackID *string) {
 	t.mutex.Lock()
 	defer t.mutex.Unlock()
@@ -512,3 +680,74 @@ func (t *LocalTracks) getFirstInitTrack(trackID *string) string {
 	defer t.mutex.RUnlock()
 	return t.firstInitTrack[*trackID]
 }
+
+func (t *LocalTracks) setRewriter(trackID *string, r *utils.Rewriter) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.rewriters[*trackID] = r
+}
+
+func (t *LocalTracks) getRewriter(trackID *string) *utils.Rewriter {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.rewriters[*trackID]
+}
+
+func (t *LocalTracks) deleteRewriter(trackID *string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.rewriters, *trackID)
+}
+
+func (t *LocalTracks) setSource(trackID *string, source string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.sources[*trackID] = source
+}
+
+func (t *LocalTracks) getSource(trackID *string) string {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return t.sources[*trackID]
+}
+
+func (t *LocalTracks) deleteSource(trackID *string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.sources, *trackID)
+}
+
+// rewrite return false if packet is late packet of old source
+func (t *LocalTracks) rewrite(trackID *string, packet *rtp.Packet) bool {
+	if r := t.getRewriter(trackID); r != nil {
+		return r.Rewrite(packet)
+	}
+	return true
+}
+
+// skip tell rewriter packet was filtered
+func (t *LocalTracks) skip(trackID *string, packet *rtp.Packet) {
+	if r := t.getRewriter(trackID); r != nil {
+		r.Skip(packet)
+	}
+}
+
+// resync continue trackID after a pause
+func (t *LocalTracks) resync(trackID *string) {
+	if r := t.getRewriter(trackID); r != nil {
+		r.Resync()
+	}
+}
+
+// switchSource mark current source of trackID as old, codecs of the new publisher is taken on next packet
+func (t *LocalTracks) switchSource(trackID *string) {
+	if r := t.getRewriter(trackID); r != nil {
+		r.Switch()
+	}
+	if track := t.getVideoTrack(trackID); track != nil {
+		track.setPublisher(nil)
+	}
+	if track := t.getAudioTrack(trackID); track != nil {
+		track.setPublisher(nil)
+	}
+}
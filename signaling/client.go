@@ -0,0 +1,484 @@
+package signaling
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/spgnk/rtc/auth"
+	"github.com/spgnk/rtc/peer"
+	"github.com/spgnk/rtc/token"
+	"github.com/spgnk/rtc/utils"
+	"github.com/spgnk/rtc/worker"
+	"golang.org/x/net/websocket"
+)
+
+// client is one websocket connection
+type client struct {
+	server   *Server
+	conn     *websocket.Conn
+	signalID string
+	claims   *auth.Claims      // claims of join token, nil without token manager
+	peers    map[string]string // save pcID - cookieID of peer created by this client
+	mutex    sync.RWMutex
+	sendLock sync.Mutex // websocket frame must be written one by one
+}
+
+func newClient(s *Server, conn *websocket.Conn) *client {
+	return &client{
+		server: s,
+		conn:   conn,
+		peers:  make(map[string]string),
+	}
+}
+
+func (c *client) handle(msg *Message) {
+	if msg.Type != TypeJoin && c.getSignalID() == "" {
+		c.sendError(msg, fmt.Errorf("join is required before %s", msg.Type))
+		return
+	}
+
+	var err error
+	switch msg.Type {
+	case TypeJoin:
+		err = c.join(msg)
+	case TypePublish:
+		err = c.publish(msg)
+	case TypeSubscribe:
+		err = c.subscribe(msg)
+	case TypeSDP:
+		err = c.setSDP(msg)
+	case TypeCandidate:
+		err = c.addCandidate(msg)
+	case TypeClose:
+		err = c.closePeer(msg)
+	default:
+		err = fmt.Errorf("unknown message type %s", msg.Type)
+	}
+
+	if err != nil {
+		c.sendError(msg, err)
+	}
+}
+
+func (c *client) join(msg *Message) error {
+	if c.getSignalID() != "" {
+		return fmt.Errorf("already joined as %s", c.getSignalID())
+	}
+
+	if m := c.server.getTokenManager(); m != nil {
+		tokenString := msg.Token
+		if tokenString == "" {
+			tokenString = token.FromRequest(c.conn.Request())
+		}
+		claims, err := m.Authenticate(tokenString)
+		if err != nil {
+			return err
+		}
+		if msg.SignalID != "" && msg.SignalID != claims.SignalID {
+			m.Release(claims.SignalID)
+			return auth.Denied(fmt.Sprintf("token is not issued for %s", msg.SignalID))
+		}
+		msg.SignalID = claims.SignalID
+		c.setClaims(claims)
+	}
+
+	if msg.SignalID == "" {
+		return fmt.Errorf("signal_id is required")
+	}
+	c.setSignalID(msg.SignalID)
+
+	w := c.server.worker
+	if w.GetConnections(&msg.SignalID) == nil {
+		w.AddConnections(&msg.SignalID)
+	}
+	return c.send(&Message{ID: msg.ID, Type: TypeOK, SignalID: msg.SignalID})
+}
+
+// publish create a peer up. Remote track is mapped to trackID by mids, or by its msid equal to trackID
+func (c *client) publish(msg *Message) error {
+	if msg.SDP == nil {
+		return fmt.Errorf("sdp offer is required")
+	}
+
+	pcID, err := c.peerConnectionID(msg)
+	if err != nil {
+		return err
+	}
+	up := &worker.UpPeer{}
+	up.SetVideoList(toList(msg.VideoTrackIDs))
+	up.SetAudioList(toList(msg.AudioTrackIDs))
+	for mid, trackID := range msg.Mids {
+		up.SetMidTrack(mid, trackID)
+	}
+	for trackID, source := range msg.Sources {
+		up.SetTrackSource(trackID, source)
+	}
+	c.server.worker.AppendUpList(&pcID, up)
+
+	role := utils.PeerUp
+	if _, err := c.addConnection(&peer.Configs{
+		TurnConfig:       c.server.turnConfig,
+		Role:             &role,
+		PeerConnectionID: &pcID,
+		AllowUpVideo:     len(msg.VideoTrackIDs) > 0,
+		AllowUpAudio:     len(msg.AudioTrackIDs) > 0,
+	}); err != nil {
+		c.server.worker.DeleteUpList(&pcID)
+		return err
+	}
+
+	if err := c.answer(msg, &pcID); err != nil {
+		c.closeOnError(pcID)
+		return err
+	}
+	return nil
+}
+
+// subscribe create a peer down. Without sdp, server send an offer and wait for sdp answer
+func (c *client) subscribe(msg *Message) error {
+	if len(msg.VideoTrackIDs) == 0 && len(msg.AudioTrackIDs) == 0 {
+		return fmt.Errorf("no track is requested")
+	}
+
+	pcID, err := c.peerConnectionID(msg)
+	if err != nil {
+		return err
+	}
+	role := utils.PeerDown
+	p, err := c.addConnection(&peer.Configs{
+		TurnConfig:       c.server.turnConfig,
+		Role:             &role,
+		PeerConnectionID: &pcID,
+		AllowDownVideo:   len(msg.VideoTrackIDs) > 0,
+		AllowDownAudio:   len(msg.AudioTrackIDs) > 0,
+	})
+	if err != nil {
+		return err
+	}
+	if err := c.negotiateDown(msg, p); err != nil {
+		c.closeOnError(pcID)
+		return err
+	}
+
+	signalID := c.getSignalID()
+	if err := c.server.worker.Register(&signalID, &pcID, msg.VideoTrackIDs, msg.AudioTrackIDs, c.handleRegisterError); err != nil {
+		c.closeOnError(pcID)
+		return err
+	}
+	return nil
+}
+
+// negotiateDown add local track to peer down then answer the offer of msg, or send an offer without it
+func (c *client) negotiateDown(msg *Message, p *peer.Peer) error {
+	w := c.server.worker
+	role := utils.PeerDown
+	for i := range msg.VideoTrackIDs {
+		trackID := msg.VideoTrackIDs[i]
+		config := peer.NewTrackConfig(&trackID, w.GetVideoCodec(&trackID), &role, nil).SetSource(w.GetTrackSource(&trackID))
+		if err := p.AddVideoTrack(config); err != nil {
+			return err
+		}
+	}
+	for i := range msg.AudioTrackIDs {
+		trackID := msg.AudioTrackIDs[i]
+		if err := p.AddAudioTrack(peer.NewTrackConfig(&trackID, utils.ModeOpus, &role, nil)); err != nil {
+			return err
+		}
+	}
+
+	if msg.SDP != nil {
+		return c.answer(msg, p.GetPeerConnectionID())
+	}
+	if err := p.CreateOffer(false); err != nil {
+		return err
+	}
+	return c.sendLocalDescription(msg, p)
+}
+
+// setSDP set remote sdp of existing peer, offer is answered
+func (c *client) setSDP(msg *Message) error {
+	if msg.SDP == nil {
+		return fmt.Errorf("sdp is required")
+	}
+	pcID := msg.PeerConnectionID
+	if !c.hasPeer(pcID) {
+		return fmt.Errorf("%s not found", pcID)
+	}
+	if msg.SDP.Type == webrtc.SDPTypeOffer.String() {
+		return c.answer(msg, &pcID)
+	}
+
+	signalID := c.getSignalID()
+	conns := c.server.worker.GetConnections(&signalID)
+	if conns == nil {
+		return fmt.Errorf("%s connections not found", signalID)
+	}
+	if err := conns.AddSDP(&pcID, *msg.SDP); err != nil {
+		return err
+	}
+	return c.send(&Message{ID: msg.ID, Type: TypeOK, PeerConnectionID: pcID})
+}
+
+func (c *client) addCandidate(msg *Message) error {
+	if msg.Candidate == nil {
+		return fmt.Errorf("candidate is required")
+	}
+	p, err := c.getPeer(msg.PeerConnectionID)
+	if err != nil {
+		return err
+	}
+	if err := p.AddICECandidate(msg.Candidate); err != nil {
+		return err
+	}
+	return c.send(&Message{ID: msg.ID, Type: TypeOK, PeerConnectionID: msg.PeerConnectionID})
+}
+
+func (c *client) closePeer(msg *Message) error {
+	pcID := msg.PeerConnectionID
+	cookieID, has := c.getCookieID(pcID)
+	if !has {
+		return fmt.Errorf("%s not found", pcID)
+	}
+	c.removePeer(pcID, cookieID)
+	return c.send(&Message{ID: msg.ID, Type: TypeOK, PeerConnectionID: pcID})
+}
+
+// close remove all peer of this client and release its claims
+func (c *client) close() {
+	c.mutex.RLock()
+	peers := make(map[string]string, len(c.peers))
+	for pcID, cookieID := range c.peers {
+		peers[pcID] = cookieID
+	}
+	c.mutex.RUnlock()
+
+	for pcID, cookieID := range peers {
+		c.removePeer(pcID, cookieID)
+	}
+
+	if m, claims := c.server.getTokenManager(), c.getClaims(); m != nil && claims != nil {
+		m.Release(claims.SignalID)
+	}
+}
+
+// closeOnError remove peer which failed its first negotiation
+func (c *client) closeOnError(pcID string) {
+	if cookieID, has := c.getCookieID(pcID); has {
+		c.removePeer(pcID, cookieID)
+	}
+}
+
+func (c *client) removePeer(pcID, cookieID string) {
+	c.mutex.Lock()
+	delete(c.peers, pcID)
+	c.mutex.Unlock()
+
+	signalID := c.getSignalID()
+	w := c.server.worker
+	w.UnRegister(&pcID)
+	w.DeleteUpList(&pcID)
+	if err := w.RemoveConnection(&signalID, &pcID, &cookieID); err != nil {
+		c.server.logger.WARN(fmt.Sprintf("%s remove connection err: %s", pcID, err.Error()), nil)
+	}
+}
+
+func (c *client) addConnection(configs *peer.Configs) (*peer.Peer, error) {
+	c.applyClaims(configs)
+	signalID := c.getSignalID()
+	p, err := c.server.worker.AddConnection(
+		&signalID,
+		configs,
+		c.handleAddPeer,
+		c.handleFailedPeer,
+		c.handleCandidate,
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	c.peers[*configs.PeerConnectionID] = *p.GetCookieID()
+	c.mutex.Unlock()
+	return p, nil
+}
+
+// answer set offer of msg to pcID and respond the answer
+func (c *client) answer(msg *Message, pcID *string) error {
+	signalID := c.getSignalID()
+	conns := c.server.worker.GetConnections(&signalID)
+	if conns == nil {
+		return fmt.Errorf("%s connections not found", signalID)
+	}
+	if err := conns.AddSDP(pcID, *msg.SDP); err != nil {
+		return err
+	}
+	p, err := c.getPeer(*pcID)
+	if err != nil {
+		return err
+	}
+	return c.sendLocalDescription(msg, p)
+}
+
+func (c *client) sendLocalDescription(msg *Message, p *peer.Peer) error {
+	desc, err := p.GetLocalDescription()
+	if err != nil {
+		return err
+	}
+	if desc == nil {
+		return fmt.Errorf("local description is nil")
+	}
+	return c.send(&Message{
+		ID:               msg.ID,
+		Type:             TypeAnswer,
+		PeerConnectionID: *p.GetPeerConnectionID(),
+		Role:             *p.GetRole(),
+		SDP:              &utils.SDPTemp{Type: desc.Type.String(), SDP: desc.SDP},
+	})
+}
+
+func (c *client) handleCandidate(signalID, peerConnectionID *string, candidate *webrtc.ICECandidate) {
+	init := candidate.ToJSON()
+	if err := c.send(&Message{
+		Type:             TypeCandidate,
+		PeerConnectionID: *peerConnectionID,
+		Candidate:        &init,
+	}); err != nil {
+		c.server.logger.WARN(fmt.Sprintf("%s send candidate err: %s", *peerConnectionID, err.Error()), nil)
+	}
+}
+
+func (c *client) handleAddPeer(signalID, role, peerConnectionID *string) {
+	if err := c.send(&Message{
+		Type:             TypeConnected,
+		PeerConnectionID: *peerConnectionID,
+		Role:             *role,
+	}); err != nil {
+		c.server.logger.WARN(fmt.Sprintf("%s send connected err: %s", *peerConnectionID, err.Error()), nil)
+	}
+}
+
+// handleFailedPeer peer was removed by worker, forget it
+func (c *client) handleFailedPeer(signalID, role, peerConnectionID *string) {
+	c.mutex.Lock()
+	delete(c.peers, *peerConnectionID)
+	c.mutex.Unlock()
+	c.server.worker.UnRegister(peerConnectionID)
+	c.server.worker.DeleteUpList(peerConnectionID)
+
+	if err := c.send(&Message{
+		Type:             TypeFailed,
+		PeerConnectionID: *peerConnectionID,
+		Role:             *role,
+	}); err != nil {
+		c.server.logger.WARN(fmt.Sprintf("%s send failed err: %s", *peerConnectionID, err.Error()), nil)
+	}
+}
+
+func (c *client) handleRegisterError(signalID, peerConnectionID, trackID *string, reason string) {
+	c.server.logger.WARN(fmt.Sprintf("%s forward %s err: %s", *peerConnectionID, *trackID, reason), nil)
+}
+
+func (c *client) send(msg *Message) error {
+	c.sendLock.Lock()
+	defer c.sendLock.Unlock()
+	return websocket.JSON.Send(c.conn, msg)
+}
+
+func (c *client) sendError(msg *Message, err error) {
+	if errSend := c.send(&Message{
+		ID:               msg.ID,
+		Type:             TypeError,
+		PeerConnectionID: msg.PeerConnectionID,
+		Error:            err.Error(),
+	}); errSend != nil {
+		c.server.logger.WARN(fmt.Sprintf("send error message err: %s", errSend.Error()), nil)
+	}
+}
+
+func (c *client) getPeer(pcID string) (*peer.Peer, error) {
+	if !c.hasPeer(pcID) {
+		return nil, fmt.Errorf("%s not found", pcID)
+	}
+	signalID := c.getSignalID()
+	p, err := c.server.worker.GetConnection(&signalID, &pcID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, fmt.Errorf("%s not found", pcID)
+	}
+	return p, nil
+}
+
+// peerConnectionID return pcID of msg, generate one if empty. pcID already in use is rejected
+func (c *client) peerConnectionID(msg *Message) (string, error) {
+	if msg.PeerConnectionID == "" {
+		return utils.GenerateID(), nil
+	}
+	// up list and forwarder are keyed by pcID only, a pcID of other peer must not be taken
+	if c.server.worker.HasPeerConnectionID(&msg.PeerConnectionID) {
+		return "", fmt.Errorf("peer_connection_id %s is already in use", msg.PeerConnectionID)
+	}
+	return msg.PeerConnectionID, nil
+}
+
+func (c *client) hasPeer(pcID string) bool {
+	_, has := c.getCookieID(pcID)
+	return has
+}
+
+func (c *client) getCookieID(pcID string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	cookieID, has := c.peers[pcID]
+	return cookieID, has
+}
+
+// applyClaims restrict Allow flag of configs to claims of join token
+func (c *client) applyClaims(configs *peer.Configs) {
+	m := c.server.getTokenManager()
+	claims := c.getClaims()
+	if m == nil || claims == nil {
+		return
+	}
+	allowed := &peer.Configs{}
+	m.ApplyConfigs(claims, allowed)
+	configs.AllowUpVideo = configs.AllowUpVideo && allowed.AllowUpVideo
+	configs.AllowUpAudio = configs.AllowUpAudio && allowed.AllowUpAudio
+	configs.AllowDownVideo = configs.AllowDownVideo && allowed.AllowDownVideo
+	configs.AllowDownAudio = configs.AllowDownAudio && allowed.AllowDownAudio
+}
+
+func (c *client) setClaims(claims *auth.Claims) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.claims = claims
+}
+
+func (c *client) getClaims() *auth.Claims {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.claims
+}
+
+func (c *client) setSignalID(signalID string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.signalID = signalID
+}
+
+func (c *client) getSignalID() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.signalID
+}
+
+func toList(trackIDs []string) map[string]string {
+	temp := make(map[string]string, len(trackIDs))
+	for _, trackID := range trackIDs {
+		temp[trackID] = ""
+	}
+	return temp
+}
@@ -0,0 +1,66 @@
+// Package signaling is a reference WebSocket signaling server run in-process with a PeerWorker.
+//
+// Every frame is a JSON Message. Request of client carry an id, the server answer with the
+// same id and type "answer", "ok" or "error". Event pushed by server has no id.
+//
+// Client request:
+//
+//	{"id":"1","type":"join","signal_id":"user-1","token":"..."}
+//	{"id":"2","type":"publish","peer_connection_id":"pc-up","video":["cam","screen"],"audio":["mic"],"mids":{"0":"cam","1":"screen","2":"mic"},"sources":{"screen":"screen"},"sdp":{"type":"offer","sdp":"..."}}
+//	{"id":"3","type":"subscribe","peer_connection_id":"pc-down","video":["cam"],"audio":["mic"],"sdp":{"type":"offer","sdp":"..."}}
+//	{"id":"4","type":"sdp","peer_connection_id":"pc-down","sdp":{"type":"answer","sdp":"..."}}
+//	{"id":"5","type":"candidate","peer_connection_id":"pc-up","candidate":{"candidate":"candidate:...","sdpMid":"0"}}
+//	{"id":"6","type":"close","peer_connection_id":"pc-up"}
+//
+// Server event:
+//
+//	{"type":"candidate","peer_connection_id":"pc-up","candidate":{...}}
+//	{"type":"connected","peer_connection_id":"pc-up","role":"up"}
+//	{"type":"failed","peer_connection_id":"pc-up","role":"up"}
+//
+// join must be the first request. publish/subscribe create a peer up/down and respond the sdp answer.
+// Track of publish is mapped by mid of its offer, or by msid equal to the trackID without mids.
+// subscribe without sdp is answered by a server offer, client send it back the answer in a sdp request.
+// sdp with an offer is answered too, an answer is only set.
+//
+// With a token manager, join need a token in the message, the Authorization header or the token query
+// param of the websocket url. signal_id is taken from the token claims.
+package signaling
+
+import (
+	"github.com/pion/webrtc/v3"
+	"github.com/spgnk/rtc/utils"
+)
+
+// Message type
+const (
+	TypeJoin      = "join"
+	TypePublish   = "publish"
+	TypeSubscribe = "subscribe"
+	TypeSDP       = "sdp"
+	TypeCandidate = "candidate"
+	TypeClose     = "close"
+
+	TypeAnswer    = "answer"
+	TypeOK        = "ok"
+	TypeError     = "error"
+	TypeConnected = "connected"
+	TypeFailed    = "failed"
+)
+
+// Message json frame of signaling
+type Message struct {
+	ID               string                   `json:"id,omitempty"` // correlation id, response echo id of request
+	Type             string                   `json:"type"`
+	SignalID         string                   `json:"signal_id,omitempty"`
+	PeerConnectionID string                   `json:"peer_connection_id,omitempty"`
+	Role             string                   `json:"role,omitempty"`    // up or down
+	VideoTrackIDs    []string                 `json:"video,omitempty"`   // published or subscribed video trackID
+	AudioTrackIDs    []string                 `json:"audio,omitempty"`   // published or subscribed audio trackID
+	Mids             map[string]string        `json:"mids,omitempty"`    // mid of publish offer - trackID
+	Sources          map[string]string        `json:"sources,omitempty"` // trackID - camera, screen or custom source of publish
+	SDP              *utils.SDPTemp           `json:"sdp,omitempty"`
+	Candidate        *webrtc.ICECandidateInit `json:"candidate,omitempty"`
+	Token            string                   `json:"token,omitempty"` // access token of join
+	Error            string                   `json:"error,omitempty"`
+}
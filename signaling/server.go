@@ -0,0 +1,111 @@
+package signaling
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/spgnk/rtc/token"
+	"github.com/spgnk/rtc/utils"
+	"github.com/spgnk/rtc/worker"
+	"golang.org/x/net/websocket"
+)
+
+var _ http.Handler = (*Server)(nil)
+
+// Server websocket signaling endpoint, each websocket is a client with one signalID
+type Server struct {
+	worker     worker.Worker
+	turnConfig *webrtc.Configuration // ice server of new peer
+	logger     utils.Log
+	tokens     *token.Manager  // nil accept join without token
+	origins    map[string]bool // allowed browser origin (scheme://host), same origin is always allowed
+	mutex      sync.RWMutex
+}
+
+// NewServer linter
+func NewServer(w worker.Worker, turnConfig *webrtc.Configuration, logger utils.Log) *Server {
+	if turnConfig == nil {
+		turnConfig = &webrtc.Configuration{}
+	}
+	return &Server{
+		worker:     w,
+		turnConfig: turnConfig,
+		logger:     logger,
+		origins:    make(map[string]bool),
+	}
+}
+
+// SetAllowedOrigins allow browser of origins (e.g. https://app.example.com) to open websocket,
+// "*" allow any origin. Request of same origin and request without Origin header is always allowed
+func (s *Server) SetAllowedOrigins(origins ...string) {
+	temp := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		temp[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.origins = temp
+}
+
+func (s *Server) isAllowedOrigin(origin *url.URL, host string) bool {
+	if strings.EqualFold(origin.Host, host) {
+		return true
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.origins["*"] || s.origins[strings.ToLower(origin.Scheme+"://"+origin.Host)]
+}
+
+// SetTokenManager require a valid token on join, signalID of client is taken from token claims.
+// Worker should use authorizer of m to enforce claims on media
+func (s *Server) SetTokenManager(m *token.Manager) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tokens = m
+}
+
+func (s *Server) getTokenManager() *token.Manager {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.tokens
+}
+
+// ServeHTTP upgrade request to websocket and serve the client until it disconnect
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	websocket.Server{Handler: s.serve, Handshake: s.handshake}.ServeHTTP(w, r)
+}
+
+// handshake reject browser of origin not allowed, websocket is not protected by CORS
+func (s *Server) handshake(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	config.Origin = origin
+	if origin == nil || s.isAllowedOrigin(origin, r.Host) {
+		return nil
+	}
+	return fmt.Errorf("origin %s is not allowed", origin.String())
+}
+
+func (s *Server) serve(conn *websocket.Conn) {
+	c := newClient(s, conn)
+	defer c.close()
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(conn, &data); err != nil {
+			return
+		}
+		msg := &Message{}
+		if err := json.Unmarshal(data, msg); err != nil {
+			c.sendError(msg, err)
+			continue
+		}
+		c.handle(msg)
+	}
+}
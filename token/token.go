@@ -0,0 +1,225 @@
+// Package token mint and verify HS256 JWT access token carrying auth.Claims
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spgnk/rtc/auth"
+	"github.com/spgnk/rtc/errs"
+	"github.com/spgnk/rtc/peer"
+)
+
+const (
+	algorithm = "HS256"
+	tokenType = "JWT"
+
+	// Query param name of token when Authorization header can not be set, e.g. browser websocket
+	Query = "token"
+	// bearer prefix of Authorization header
+	bearer = "Bearer "
+)
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+// payload is auth.Claims with registered claims of JWT
+type payload struct {
+	auth.Claims
+	IssuedAt  int64 `json:"iat,omitempty"`
+	NotBefore int64 `json:"nbf,omitempty"`
+	ExpiresAt int64 `json:"exp,omitempty"`
+}
+
+// Manager mint and verify token signed by a shared secret.
+// Claims of authenticated token is saved in authorizer so worker enforce it without calling backend,
+// it is deleted when every connection authenticated with signalID is released
+type Manager struct {
+	secret     []byte
+	authorizer *auth.ClaimsAuthorizer // could be nil
+	refs       map[string]int         // save signalID - number of connection not released
+	mutex      sync.Mutex
+}
+
+// NewManager linter
+func NewManager(secret []byte, authorizer *auth.ClaimsAuthorizer) *Manager {
+	return &Manager{
+		secret:     secret,
+		authorizer: authorizer,
+		refs:       make(map[string]int),
+	}
+}
+
+// GetAuthorizer linter
+func (m *Manager) GetAuthorizer() *auth.ClaimsAuthorizer {
+	return m.authorizer
+}
+
+// Mint return signed token of claims, valid for ttl. ttl 0 never expire
+func (m *Manager) Mint(claims *auth.Claims, ttl time.Duration) (string, error) {
+	if claims == nil || claims.SignalID == "" {
+		return "", fmt.Errorf("signal_id of claims is required")
+	}
+
+	now := time.Now()
+	p := &payload{
+		Claims:   *claims,
+		IssuedAt: now.Unix(),
+	}
+	if ttl > 0 {
+		p.ExpiresAt = now.Add(ttl).Unix()
+	}
+
+	h, err := encode(&header{Algorithm: algorithm, Type: tokenType})
+	if err != nil {
+		return "", err
+	}
+	body, err := encode(p)
+	if err != nil {
+		return "", err
+	}
+	unsigned := h + "." + body
+	return unsigned + "." + m.sign(unsigned), nil
+}
+
+// Verify check signature and validity time of token and return its claims.
+// Every error wrap auth.ErrDenied
+func (m *Manager) Verify(token string) (*auth.Claims, error) {
+	if token == "" {
+		return nil, invalid(errs.ErrT001)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalid(errs.ErrT002)
+	}
+
+	h := &header{}
+	if err := decode(parts[0], h); err != nil {
+		return nil, invalid(errs.ErrT002)
+	}
+	// only HS256 is accepted, "none" must never pass
+	if h.Algorithm != algorithm {
+		return nil, invalid(errs.ErrT003)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalid(errs.ErrT002)
+	}
+	if !hmac.Equal(signature, m.mac(parts[0]+"."+parts[1])) {
+		return nil, invalid(errs.ErrT003)
+	}
+
+	p := &payload{}
+	if err := decode(parts[1], p); err != nil {
+		return nil, invalid(errs.ErrT002)
+	}
+	now := time.Now().Unix()
+	if p.ExpiresAt != 0 && now >= p.ExpiresAt {
+		return nil, invalid(errs.ErrT004)
+	}
+	if p.NotBefore != 0 && now < p.NotBefore {
+		return nil, invalid(errs.ErrT005)
+	}
+	if p.SignalID == "" {
+		return nil, invalid(errs.ErrT002)
+	}
+	if p.ExpiresAt != 0 {
+		p.Claims.ExpiresAt = time.Unix(p.ExpiresAt, 0)
+	}
+	return &p.Claims, nil
+}
+
+// Authenticate verify token and save its claims in authorizer.
+// Each success must be paired with a Release of claims.SignalID once the connection end
+func (m *Manager) Authenticate(token string) (*auth.Claims, error) {
+	claims, err := m.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.refs[claims.SignalID]++
+	if m.authorizer != nil {
+		m.authorizer.SetClaims(claims)
+	}
+	return claims, nil
+}
+
+// Release end a connection authenticated with signalID, claims is deleted from authorizer with the last one
+func (m *Manager) Release(signalID string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	switch m.refs[signalID] {
+	case 0:
+		return
+	case 1:
+		delete(m.refs, signalID)
+	default:
+		m.refs[signalID]--
+		return
+	}
+	if m.authorizer != nil {
+		m.authorizer.DeleteClaims(signalID)
+	}
+}
+
+// ApplyConfigs fill Allow flag of configs from claims. Role policy of authorizer is counted
+func (m *Manager) ApplyConfigs(claims *auth.Claims, configs *peer.Configs) {
+	publish, subscribe := len(claims.Publish) > 0, len(claims.Subscribe) > 0
+	if m.authorizer != nil {
+		publish, subscribe, _ = m.authorizer.Permissions(claims.SignalID)
+	}
+	configs.AllowUpVideo = publish
+	configs.AllowUpAudio = publish
+	configs.AllowDownVideo = subscribe
+	configs.AllowDownAudio = subscribe
+}
+
+// FromRequest return token of Authorization bearer header or token query param
+func FromRequest(r *http.Request) string {
+	if value := r.Header.Get("Authorization"); strings.HasPrefix(value, bearer) {
+		return strings.TrimSpace(strings.TrimPrefix(value, bearer))
+	}
+	return r.URL.Query().Get(Query)
+}
+
+func (m *Manager) sign(unsigned string) string {
+	return base64.RawURLEncoding.EncodeToString(m.mac(unsigned))
+}
+
+func (m *Manager) mac(unsigned string) []byte {
+	h := hmac.New(sha256.New, m.secret)
+	h.Write([]byte(unsigned))
+	return h.Sum(nil)
+}
+
+func encode(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decode(segment string, value interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+// invalid wrap err as a denial
+func invalid(err error) error {
+	return fmt.Errorf("%w: %w", auth.ErrDenied, err)
+}
@@ -0,0 +1,219 @@
+package token
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spgnk/rtc/auth"
+	"github.com/spgnk/rtc/errs"
+)
+
+var testSecret = []byte("secret")
+
+// mintPayload sign payload with header alg, the way a forged or future token would be built
+func mintPayload(t *testing.T, secret []byte, alg string, p *payload) string {
+	t.Helper()
+	h, err := encode(&header{Algorithm: alg, Type: tokenType})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := encode(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := h + "." + body
+	return unsigned + "." + NewManager(secret, nil).sign(unsigned)
+}
+
+func expectDenied(t *testing.T, err error, code error) {
+	t.Helper()
+	if err == nil {
+		t.Fatal("token is accepted")
+	}
+	if !auth.IsDenied(err) {
+		t.Errorf("error %v does not wrap auth.ErrDenied", err)
+	}
+	if !errors.Is(err, code) {
+		t.Errorf("error %v, want %v", err, code)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	m := NewManager(testSecret, nil)
+	token, err := m.Mint(&auth.Claims{SignalID: "alice", Publish: []string{"cam"}}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := m.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.SignalID != "alice" || len(claims.Publish) != 1 || claims.Publish[0] != "cam" {
+		t.Errorf("claims = %+v", claims)
+	}
+	if claims.ExpiresAt.IsZero() || claims.Expired(time.Now()) {
+		t.Errorf("claims expires at %v", claims.ExpiresAt)
+	}
+}
+
+func TestVerifyTamperedSignature(t *testing.T) {
+	m := NewManager(testSecret, nil)
+	token, err := m.Mint(&auth.Claims{SignalID: "alice"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+
+	// payload changed to claim more permission
+	forged, err := encode(&payload{Claims: auth.Claims{SignalID: "alice", Publish: []string{"*"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = m.Verify(parts[0] + "." + forged + "." + parts[2])
+	expectDenied(t, err, errs.ErrT003)
+
+	// signature of another secret
+	_, err = m.Verify(mintPayload(t, []byte("other"), algorithm, &payload{Claims: auth.Claims{SignalID: "alice"}}))
+	expectDenied(t, err, errs.ErrT003)
+
+	// signature flipped
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	signature[0] ^= 0xff
+	_, err = m.Verify(parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(signature))
+	expectDenied(t, err, errs.ErrT003)
+}
+
+func TestVerifyAlgorithmNone(t *testing.T) {
+	m := NewManager(testSecret, nil)
+	h, _ := encode(&header{Algorithm: "none", Type: tokenType})
+	body, _ := encode(&payload{Claims: auth.Claims{SignalID: "alice"}})
+
+	_, err := m.Verify(h + "." + body + ".")
+	expectDenied(t, err, errs.ErrT003)
+
+	// alg none with a valid HS256 signature must still be rejected
+	_, err = m.Verify(mintPayload(t, testSecret, "none", &payload{Claims: auth.Claims{SignalID: "alice"}}))
+	expectDenied(t, err, errs.ErrT003)
+}
+
+func TestVerifyTime(t *testing.T) {
+	m := NewManager(testSecret, nil)
+	now := time.Now()
+
+	_, err := m.Verify(mintPayload(t, testSecret, algorithm, &payload{
+		Claims:    auth.Claims{SignalID: "alice"},
+		ExpiresAt: now.Add(-time.Second).Unix(),
+	}))
+	expectDenied(t, err, errs.ErrT004)
+
+	_, err = m.Verify(mintPayload(t, testSecret, algorithm, &payload{
+		Claims:    auth.Claims{SignalID: "alice"},
+		NotBefore: now.Add(time.Minute).Unix(),
+	}))
+	expectDenied(t, err, errs.ErrT005)
+
+	claims, err := m.Verify(mintPayload(t, testSecret, algorithm, &payload{
+		Claims:    auth.Claims{SignalID: "alice"},
+		NotBefore: now.Add(-time.Minute).Unix(),
+		ExpiresAt: now.Add(time.Minute).Unix(),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !claims.Expired(now.Add(2 * time.Minute)) {
+		t.Error("claims is not expired after exp")
+	}
+
+	// ttl 0 never expire
+	token, err := m.Mint(&auth.Claims{SignalID: "alice"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err = m.Verify(token); err != nil || !claims.ExpiresAt.IsZero() {
+		t.Errorf("token without exp: claims %+v err %v", claims, err)
+	}
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	m := NewManager(testSecret, nil)
+
+	_, err := m.Verify("")
+	expectDenied(t, err, errs.ErrT001)
+	_, err = m.Verify("a.b")
+	expectDenied(t, err, errs.ErrT002)
+	_, err = m.Verify("!.b.c")
+	expectDenied(t, err, errs.ErrT002)
+	_, err = m.Verify(mintPayload(t, testSecret, algorithm, &payload{}))
+	expectDenied(t, err, errs.ErrT002)
+}
+
+func TestAuthenticateRelease(t *testing.T) {
+	authorizer := auth.NewClaimsAuthorizer()
+	m := NewManager(testSecret, authorizer)
+	token, err := m.Mint(&auth.Claims{SignalID: "alice", Publish: []string{"cam"}}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 2 connection of the same signalID
+	for i := 0; i < 2; i++ {
+		if _, err := m.Authenticate(token); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := authorizer.CanPublish("alice", "", "cam", "video"); err != nil {
+		t.Fatal(err)
+	}
+
+	m.Release("alice")
+	if authorizer.GetClaims("alice") == nil {
+		t.Fatal("claims is deleted while a connection is alive")
+	}
+	m.Release("alice")
+	if authorizer.GetClaims("alice") != nil {
+		t.Fatal("claims is kept after the last connection")
+	}
+	if err := authorizer.CanPublish("alice", "", "cam", "video"); !auth.IsDenied(err) {
+		t.Errorf("publish after release err = %v", err)
+	}
+
+	// release without authenticate keep claims set by app
+	authorizer.SetClaims(&auth.Claims{SignalID: "bob"})
+	m.Release("bob")
+	if authorizer.GetClaims("bob") == nil {
+		t.Error("claims of app is deleted")
+	}
+}
+
+func TestExpiredClaimsDenied(t *testing.T) {
+	authorizer := auth.NewClaimsAuthorizer()
+	authorizer.SetClaims(&auth.Claims{
+		SignalID:  "alice",
+		Publish:   []string{"*"},
+		ExpiresAt: time.Now().Add(-time.Second),
+	})
+
+	err := authorizer.CanPublish("alice", "", "cam", "video")
+	if !auth.IsDenied(err) || !strings.Contains(err.Error(), errs.ErrA003.Error()) {
+		t.Errorf("publish with expired claims err = %v", err)
+	}
+	if publish, _, _ := authorizer.Permissions("alice"); publish {
+		t.Error("expired claims still grant publish")
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	r := httptest.NewRequest("GET", "/?token=query", nil)
+	if got := FromRequest(r); got != "query" {
+		t.Errorf("FromRequest = %s, want query", got)
+	}
+	r.Header.Set("Authorization", "Bearer header")
+	if got := FromRequest(r); got != "header" {
+		t.Errorf("FromRequest = %s, want header", got)
+	}
+}
@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"github.com/pion/rtp"
+)
+
+// AudioLevelURI rtp header extension carry audio level of packet (rfc6464)
+const AudioLevelURI = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+
+// ParseAudioLevel return audio level (0 is loudest, 127 is silent) and voice flag of raw rtp packet.
+// id is the negotiated extension id
+func ParseAudioLevel(data []byte, id uint8) (uint8, bool, bool) {
+	header := &rtp.Header{}
+	if _, err := header.Unmarshal(data); err != nil {
+		return 0, false, false
+	}
+	payload := header.GetExtension(id)
+	if payload == nil {
+		return 0, false, false
+	}
+	ext := &rtp.AudioLevelExtension{}
+	if err := ext.Unmarshal(payload); err != nil {
+		return 0, false, false
+	}
+	return ext.Level, ext.Voice, true
+}
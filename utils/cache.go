@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+// DefaultCacheSize number of packet keep in cache of each forwarder
+const DefaultCacheSize = 1024
+
+type cacheEntry struct {
+	valid bool
+	ssrc  uint32
+	seq   uint16
+	data  []byte
+}
+
+// PacketCache ring buffer of recently forwarded rtp packet, use to answer NACK
+type PacketCache struct {
+	entries []cacheEntry
+	mutex   sync.RWMutex
+}
+
+// NewPacketCache linter
+func NewPacketCache(size int) *PacketCache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	c := &PacketCache{
+		entries: make([]cacheEntry, size),
+	}
+	for i := range c.entries {
+		c.entries[i].data = make([]byte, 0, MaxMTU)
+	}
+	return c
+}
+
+// Put copy raw rtp packet into cache
+func (c *PacketCache) Put(data []byte) {
+	if len(data) < 12 {
+		return
+	}
+	seq := binary.BigEndian.Uint16(data[2:4])
+	ssrc := binary.BigEndian.Uint32(data[8:12])
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry := &c.entries[int(seq)%len(c.entries)]
+	entry.valid = true
+	entry.ssrc = ssrc
+	entry.seq = seq
+	entry.data = append(entry.data[:0], data...)
+}
+
+// Get return copy of raw rtp packet of ssrc with sequence number seq
+func (c *PacketCache) Get(ssrc uint32, seq uint16) ([]byte, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	entry := &c.entries[int(seq)%len(c.entries)]
+	if !entry.valid || entry.ssrc != ssrc || entry.seq != seq {
+		return nil, false
+	}
+	data := make([]byte, len(entry.data))
+	copy(data, entry.data)
+	return data, true
+}
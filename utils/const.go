@@ -1,5 +1,10 @@
 package utils
 
+import (
+	"fmt"
+	"strings"
+)
+
 // const splitStr = "-"
 
 const (
@@ -15,6 +20,12 @@ const (
 	// MimeTypeVP9 VP9 MIME type
 	// Note: Matching should be case insensitive.
 	MimeTypeVP9 = "video/VP9"
+	// MimeTypeH265 H265 MIME type
+	// Note: Matching should be case insensitive.
+	MimeTypeH265 = "video/H265"
+	// MimeTypeAV1 AV1 MIME type
+	// Note: Matching should be case insensitive.
+	MimeTypeAV1 = "video/AV1"
 	// MimeTypeG722 G722 MIME type
 	// Note: Matching should be case insensitive.
 	MimeTypeG722 = "audio/G722"
@@ -49,20 +60,28 @@ const (
 	ModeVP9 = "vp9"
 	// ModeH264 linter
 	ModeH264 = "h264"
+	// ModeH265 linter
+	ModeH265 = "h265"
+	// ModeAV1 linter
+	ModeAV1 = "av1"
 	// ModeOpus linter
 	ModeOpus = "opus"
 )
 
-// GetModeType get mimetype codec
+// GetModeType get mimetype codec, mimetype is case insensitive
 func GetModeType(codec string) string {
-	switch codec {
-	case MimeTypeVP8:
+	switch strings.ToLower(codec) {
+	case strings.ToLower(MimeTypeVP8):
 		return ModeVP8
-	case MimeTypeVP9:
+	case strings.ToLower(MimeTypeVP9):
 		return ModeVP9
-	case MimeTypeH264:
+	case strings.ToLower(MimeTypeH264):
 		return ModeH264
-	case MimeTypeOpus:
+	case strings.ToLower(MimeTypeH265):
+		return ModeH265
+	case strings.ToLower(MimeTypeAV1):
+		return ModeAV1
+	case strings.ToLower(MimeTypeOpus):
 		return ModeOpus
 	default:
 		return ModeVP9
@@ -78,6 +97,10 @@ func GetCodec(codec *string) string {
 		return MimeTypeVP9
 	case ModeH264:
 		return MimeTypeH264
+	case ModeH265:
+		return MimeTypeH265
+	case ModeAV1:
+		return MimeTypeAV1
 	case ModeOpus:
 		return MimeTypeOpus
 	default:
@@ -94,8 +117,26 @@ var (
 	DefaultPayloadVP8 = 96
 	// DefaultPayloadVP9 linter
 	DefaultPayloadVP9 = 98
+	// DefaultPayloadAV1 linter
+	DefaultPayloadAV1 = 45
+	// DefaultPayloadH265 linter, main 10 profile use DefaultPayloadH265+2
+	DefaultPayloadH265 = 49
 )
 
+// hevc profile-id of fmtp
+const (
+	// H265ProfileMain linter
+	H265ProfileMain = 1
+	// H265ProfileMain10 linter
+	H265ProfileMain10 = 2
+)
+
+// H265Fmtp return hevc fmtp of profileID in main tier. level-id is left out so offer of any level match
+// by profile-id and tier-flag, receiver default to level 3.1 (93)
+func H265Fmtp(profileID int) string {
+	return fmt.Sprintf("profile-id=%d;tier-flag=0", profileID)
+}
+
 // Peer Role
 const (
 	// SplitStr linter
@@ -112,6 +153,24 @@ const (
 	MemberType = "member"
 )
 
+// Simulcast rid
+const (
+	// RIDQuarter quarter resolution layer
+	RIDQuarter = "q"
+	// RIDHalf half resolution layer
+	RIDHalf = "h"
+	// RIDFull full resolution layer
+	RIDFull = "f"
+)
+
+// Track source, any other value is a custom source
+const (
+	// SourceCamera linter
+	SourceCamera = "camera"
+	// SourceScreen screen share, favor resolution over framerate
+	SourceScreen = "screen"
+)
+
 const (
 	// SampleTrackType linter
 	SampleTrackType = "sample"
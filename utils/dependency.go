@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"sync"
+
+	"github.com/pion/rtp"
+)
+
+// DependencyDescriptorURI rtp header extension carry frame dependency and layer of av1 (av1 rtp spec)
+const DependencyDescriptorURI = "https://aomediacodec.github.io/av1-rtp-spec/#dependency-descriptor-rtp-header-extension"
+
+// decode target indication of a template
+const (
+	ddMaxTemplates = 64
+	ddSwitch       = 2
+)
+
+// ddTemplate layer of frame using the template
+type ddTemplate struct {
+	sid       uint8
+	tid       uint8
+	switching bool // switch indication for one of decode target
+}
+
+// DependencyParser parse layer of av1 packet from dependency descriptor extension.
+// Template structure is only sent with keyframe, it is kept for the next packets
+type DependencyParser struct {
+	id        uint8 // negotiated extension id
+	offset    int   // template id offset of structure
+	templates []ddTemplate
+	mutex     sync.Mutex
+}
+
+// NewDependencyParser linter
+func NewDependencyParser(id uint8) *DependencyParser {
+	return &DependencyParser{
+		id: id,
+	}
+}
+
+// Parse return nil if packet has no descriptor or template structure is not received yet.
+// Frame carrying a new structure is not predicted
+func (d *DependencyParser) Parse(pkg *rtp.Packet) *Layer {
+	data := pkg.GetExtension(d.id)
+	if len(data) < 3 {
+		return nil
+	}
+
+	r := &bitReader{data: data}
+	start := r.read(1) == 1
+	end := r.read(1) == 1
+	templateID := int(r.read(6))
+	r.read(16) // frame number
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	structure := false
+	if len(data) > 3 {
+		structure = r.read(1) == 1
+		// active decode targets, custom dtis, custom fdiffs, custom chains flag
+		r.read(4)
+		if structure {
+			offset, templates, ok := readTemplateStructure(r)
+			if !ok {
+				return nil
+			}
+			d.offset = offset
+			d.templates = templates
+		}
+	}
+
+	index := (templateID - d.offset + ddMaxTemplates) % ddMaxTemplates
+	if index >= len(d.templates) {
+		return nil
+	}
+	t := d.templates[index]
+	return &Layer{
+		SID:       t.sid,
+		TID:       t.tid,
+		Start:     start,
+		End:       end,
+		Switch:    t.switching,
+		Predicted: !structure,
+	}
+}
+
+// readTemplateStructure read template id offset, layer and dti of every template
+func readTemplateStructure(r *bitReader) (int, []ddTemplate, bool) {
+	offset := int(r.read(6))
+	dtCount := int(r.read(5)) + 1
+
+	var templates []ddTemplate
+	var sid, tid uint8
+	for {
+		if len(templates) == ddMaxTemplates {
+			return 0, nil, false
+		}
+		templates = append(templates, ddTemplate{sid: sid, tid: tid})
+		next := r.read(2)
+		if next == 3 {
+			break
+		}
+		switch next {
+		case 1:
+			tid++
+		case 2:
+			tid = 0
+			sid++
+		}
+		if r.err {
+			return 0, nil, false
+		}
+	}
+
+	for i := range templates {
+		for j := 0; j < dtCount; j++ {
+			if r.read(2) == ddSwitch {
+				templates[i].switching = true
+			}
+		}
+	}
+	if r.err {
+		return 0, nil, false
+	}
+	return offset, templates, true
+}
+
+// bitReader read msb first, err is set when data is over
+type bitReader struct {
+	data []byte
+	pos  int // bit position
+	err  bool
+}
+
+func (b *bitReader) read(n int) uint32 {
+	var value uint32
+	for i := 0; i < n; i++ {
+		index := b.pos / 8
+		if index >= len(b.data) {
+			b.err = true
+			return 0
+		}
+		bit := (b.data[index] >> (7 - uint(b.pos%8))) & 0x01
+		value = value<<1 | uint32(bit)
+		b.pos++
+	}
+	return value
+}
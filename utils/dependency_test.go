@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+const testDependencyID = 3
+
+// bitWriter write msb first like dependency descriptor
+type bitWriter struct {
+	data []byte
+	pos  int
+}
+
+func (b *bitWriter) write(n int, value uint32) *bitWriter {
+	for i := n - 1; i >= 0; i-- {
+		if b.pos/8 >= len(b.data) {
+			b.data = append(b.data, 0)
+		}
+		if (value>>uint(i))&1 == 1 {
+			b.data[b.pos/8] |= 1 << (7 - uint(b.pos%8))
+		}
+		b.pos++
+	}
+	return b
+}
+
+// mandatory field of descriptor
+func (b *bitWriter) frame(start, end bool, templateID, frameNumber uint32) *bitWriter {
+	return b.write(1, boolBit(start)).write(1, boolBit(end)).write(6, templateID).write(16, frameNumber)
+}
+
+// structure write template structure with layer change of next template and dti of every template
+func (b *bitWriter) structure(offset uint32, nextLayers []uint32, dtis [][]uint32) *bitWriter {
+	b.write(1, 1) // template dependency structure present
+	b.write(4, 0) // active decode targets, custom dtis, custom fdiffs, custom chains
+	b.write(6, offset)
+	b.write(5, uint32(len(dtis[0])-1))
+	for _, next := range nextLayers {
+		b.write(2, next)
+	}
+	for _, dti := range dtis {
+		for _, value := range dti {
+			b.write(2, value)
+		}
+	}
+	return b
+}
+
+func boolBit(value bool) uint32 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func packetWith(descriptor []byte) *rtp.Packet {
+	pkg := &rtp.Packet{}
+	if err := pkg.SetExtension(testDependencyID, descriptor); err != nil {
+		panic(err)
+	}
+	return pkg
+}
+
+func TestDependencyParserTemporalLayers(t *testing.T) {
+	p := NewDependencyParser(testDependencyID)
+
+	// L1T2: templates (s0,t0) (s0,t0) (s0,t1), 3 decode targets
+	key := (&bitWriter{}).frame(true, false, 5, 100).structure(5,
+		[]uint32{0, 1, 3},
+		[][]uint32{{3, 3, 3}, {3, 3, 3}, {0, 2, 3}},
+	)
+	layer := p.Parse(packetWith(key.data))
+	if layer == nil {
+		t.Fatal("keyframe with structure is not parsed")
+	}
+	if !layer.Keyframe() || layer.SID != 0 || layer.TID != 0 || layer.Switch || !layer.Start || layer.End {
+		t.Fatalf("keyframe layer = %+v", layer)
+	}
+
+	// next packet only carry mandatory field, structure of keyframe is kept
+	delta := (&bitWriter{}).frame(false, true, 7, 101)
+	layer = p.Parse(packetWith(delta.data))
+	if layer == nil {
+		t.Fatal("delta frame is not parsed")
+	}
+	if layer.Keyframe() || !layer.Predicted || layer.TID != 1 || !layer.Switch || !layer.End {
+		t.Fatalf("delta layer = %+v", layer)
+	}
+}
+
+func TestDependencyParserSpatialLayers(t *testing.T) {
+	p := NewDependencyParser(testDependencyID)
+
+	// L2T2: templates (s0,t0) (s0,t1) (s1,t0) (s1,t1)
+	key := (&bitWriter{}).frame(true, true, 0, 1).structure(0,
+		[]uint32{1, 2, 1, 3},
+		[][]uint32{{2, 2}, {1, 1}, {0, 2}, {0, 1}},
+	)
+	if layer := p.Parse(packetWith(key.data)); layer == nil || !layer.Keyframe() {
+		t.Fatalf("keyframe layer = %+v", layer)
+	}
+
+	want := []struct {
+		sid, tid uint8
+		switched bool
+	}{{0, 0, true}, {0, 1, false}, {1, 0, true}, {1, 1, false}}
+	for templateID, w := range want {
+		layer := p.Parse(packetWith((&bitWriter{}).frame(true, true, uint32(templateID), 2).data))
+		if layer == nil {
+			t.Fatalf("template %d is not parsed", templateID)
+		}
+		if layer.SID != w.sid || layer.TID != w.tid || layer.Switch != w.switched {
+			t.Errorf("template %d layer = %+v, want sid %d tid %d switch %v", templateID, layer, w.sid, w.tid, w.switched)
+		}
+	}
+}
+
+func TestDependencyParserTemplateIDWrap(t *testing.T) {
+	p := NewDependencyParser(testDependencyID)
+
+	// offset 62, template index 2 has id (62 + 2) % 64 = 0
+	key := (&bitWriter{}).frame(true, true, 62, 1).structure(62,
+		[]uint32{1, 1, 3},
+		[][]uint32{{2}, {2}, {2}},
+	)
+	if layer := p.Parse(packetWith(key.data)); layer == nil {
+		t.Fatal("keyframe is not parsed")
+	}
+	layer := p.Parse(packetWith((&bitWriter{}).frame(true, true, 0, 2).data))
+	if layer == nil || layer.TID != 2 {
+		t.Fatalf("wrapped template layer = %+v, want tid 2", layer)
+	}
+	if layer := p.Parse(packetWith((&bitWriter{}).frame(true, true, 10, 3).data)); layer != nil {
+		t.Fatalf("template out of structure layer = %+v, want nil", layer)
+	}
+}
+
+func TestDependencyParserInvalid(t *testing.T) {
+	p := NewDependencyParser(testDependencyID)
+
+	if layer := p.Parse(&rtp.Packet{}); layer != nil {
+		t.Errorf("packet without descriptor layer = %+v", layer)
+	}
+	if layer := p.Parse(packetWith([]byte{0x80, 0x00})); layer != nil {
+		t.Errorf("short descriptor layer = %+v", layer)
+	}
+	// delta frame before any structure
+	if layer := p.Parse(packetWith((&bitWriter{}).frame(true, true, 0, 1).data)); layer != nil {
+		t.Errorf("descriptor without structure layer = %+v", layer)
+	}
+	// structure cut in the middle of dti
+	key := (&bitWriter{}).frame(true, true, 0, 1).structure(0,
+		[]uint32{1, 3},
+		[][]uint32{{2, 2, 2}, {1, 1, 1}},
+	)
+	if layer := p.Parse(packetWith(key.data[:len(key.data)-1])); layer != nil {
+		t.Errorf("truncated structure layer = %+v", layer)
+	}
+}
@@ -3,7 +3,9 @@ package utils
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lamhai1401/gologs/logs"
@@ -23,14 +25,25 @@ const (
 	add         = "add"
 	closing     = "closing"
 	hub         = "hub"
+
+	// keyframeRetry re-request keyframe if client still waiting after this duration
+	keyframeRetry = time.Second
 )
 
 // Client linter
 type Client struct {
-	chann      chan *Wrapper
-	handler    func(trackID string, wrapper *Wrapper) error
-	ctx        context.Context
-	cancelFunc context.CancelFunc
+	chann           chan *Wrapper
+	handler         func(trackID string, wrapper *Wrapper) error
+	ctx             context.Context
+	cancelFunc      context.CancelFunc
+	waitKeyframe    bool                        // hold all packet until the first keyframe
+	lastKeyframeReq time.Time                   // last time request keyframe for this client
+	policy          DropPolicy                  // what to do when chann is full
+	overflow        bool                        // client is waiting keyframe because chann was full
+	dropped         uint64                      // number of packet was dropped
+	onStart         func()                      // call once when client receive the first packet
+	filter          atomic.Pointer[LayerFilter] // svc layer filter, nil is forward all layer
+	paused          bool                        // stop forwarding to client
 }
 
 // Wrapper linter
@@ -41,6 +54,8 @@ type Wrapper struct {
 	Kind     *string     `json:"kind"`   // audio or video
 	SeatID   *int        `json:"seatID"` // stream id number 1-2-3-4
 	Type     *string     `json:"type"`   // type off wrapper data - ok - ping - pong
+	Layer    *Layer      // svc layer of packet, only parse if any client filter layer
+	Skip     bool        // packet is filtered for this client, only use to keep seq continuous
 }
 
 // Action linter
@@ -54,6 +69,7 @@ type Action struct {
 // Forwarder linter
 type Forwarder struct {
 	id         string // stream id
+	codec      string // mimetype of publishing track
 	isClosed   bool
 	clients    map[string]*Client // save all client with handler
 	hub        chan *Wrapper      // dispatch all data
@@ -62,7 +78,18 @@ type Forwarder struct {
 	cancelFunc context.CancelFunc
 	// lastReceiveData int64
 	dataTimeChann chan *ClientDataTime
-	mutex         sync.RWMutex
+	// keyframeHandler request upstream keyframe for this fwd id
+	keyframeHandler func(trackID string)
+	policy          DropPolicy              // default policy of new client
+	policies        map[string]DropPolicy   // save clientID - policy
+	layers          map[string]*LayerFilter // save clientID - svc layer filter
+	paused          map[string]bool         // save clientID - paused
+	cache           *PacketCache            // recently forwarded packet to answer NACK
+	audioLevelID    uint8                   // negotiated audio level extension id, 0 is disable
+	dependency      *DependencyParser       // av1 dependency descriptor parser, nil is disable
+	// audioLevelHandler receive audio level of each packet
+	audioLevelHandler func(trackID string, level uint8, voice bool)
+	mutex             sync.RWMutex
 }
 
 // NewForwarder return new forwarder
@@ -77,6 +104,10 @@ func NewForwarder(id string, dataTimeChann chan *ClientDataTime) *Forwarder {
 		ctx:           ctx,
 		cancelFunc:    cancel,
 		dataTimeChann: dataTimeChann,
+		policy:        PolicyBlock,
+		policies:      make(map[string]DropPolicy),
+		layers:        make(map[string]*LayerFilter),
+		paused:        make(map[string]bool),
 		// lastReceiveData: 0,
 	}
 
@@ -162,8 +193,91 @@ func (f *Forwarder) forward(wrapper *Wrapper) {
 		f.mutex.RUnlock()
 		handlepanic(nil)
 	}()
+
+	if f.cache != nil {
+		f.cache.Put(wrapper.Data)
+	}
+
+	if f.audioLevelID != 0 && f.audioLevelHandler != nil {
+		if level, voice, ok := ParseAudioLevel(wrapper.Data, f.audioLevelID); ok {
+			f.audioLevelHandler(f.getID(), level, voice)
+		}
+	}
+
+	// parse svc layer once for all client, av1 descriptor is always parsed to keep its template structure
+	if len(f.layers) > 0 || f.dependency != nil {
+		wrapper.Layer = f.parseLayer(wrapper)
+	}
+
+	var keyframe *bool
 	for _, client := range f.clients {
-		client.chann <- wrapper
+		if client.paused {
+			continue
+		}
+		if client.waitKeyframe {
+			if keyframe == nil {
+				state := f.isKeyframe(wrapper)
+				keyframe = &state
+			}
+			if !*keyframe {
+				if client.overflow {
+					client.drop()
+				}
+				f.retryKeyframe(client)
+				continue
+			}
+			client.waitKeyframe = false
+			client.overflow = false
+			if client.onStart != nil {
+				client.onStart()
+				client.onStart = nil
+			}
+		}
+		f.send(client, wrapper)
+	}
+}
+
+// isKeyframe return true if wrapper can start a new client. Codec we cannot parse always pass
+func (f *Forwarder) isKeyframe(wrapper *Wrapper) bool {
+	if !NeedKeyframe(f.codec) {
+		return true
+	}
+	pkg := &rtp.Packet{}
+	if err := pkg.Unmarshal(wrapper.Data); err != nil {
+		return false
+	}
+	return IsKeyframe(f.codec, pkg.Payload)
+}
+
+// parseLayer return vp9 svc layer or av1 layer of dependency descriptor of wrapper
+func (f *Forwarder) parseLayer(wrapper *Wrapper) *Layer {
+	av1 := f.dependency != nil && strings.EqualFold(f.codec, MimeTypeAV1)
+	if !av1 && !strings.EqualFold(f.codec, MimeTypeVP9) {
+		return nil
+	}
+	pkg := &rtp.Packet{}
+	if err := pkg.Unmarshal(wrapper.Data); err != nil {
+		return nil
+	}
+	if av1 {
+		return f.dependency.Parse(pkg)
+	}
+	return ParseVP9Layer(pkg.Payload)
+}
+
+// retryKeyframe request keyframe again if client wait too long
+func (f *Forwarder) retryKeyframe(client *Client) {
+	if time.Since(client.lastKeyframeReq) < keyframeRetry {
+		return
+	}
+	client.lastKeyframeReq = time.Now()
+	f.requestKeyframe()
+}
+
+// requestKeyframe ask publisher of this fwd to send a new keyframe
+func (f *Forwarder) requestKeyframe() {
+	if handler := f.keyframeHandler; handler != nil {
+		go handler(f.getID())
 	}
 }
 
@@ -203,11 +317,18 @@ func (f *Forwarder) Push(wrapper *Wrapper) {
 
 // UnRegister linter
 func (f *Forwarder) UnRegister(clientID *string) {
+	f.deleteClientSettings(clientID)
 	f.RemoveClient(clientID)
 }
 
 // Register linter
 func (f *Forwarder) Register(clientID *string, handler func(trackID string, wrapper *Wrapper) error) {
+	f.RegisterWithStart(clientID, handler, nil)
+}
+
+// RegisterWithStart register client, onStart is called once when client start receive data (on keyframe for video).
+// onStart must not block, it run inside fwd dispatch loop
+func (f *Forwarder) RegisterWithStart(clientID *string, handler func(trackID string, wrapper *Wrapper) error, onStart func()) {
 	// remove client if exist
 	// if f.getClient(clientID) != nil {
 	// 	f.info(*clientID, " already exist. No need register")
@@ -218,14 +339,25 @@ func (f *Forwarder) Register(clientID *string, handler func(trackID string, wrap
 
 	ctx, cancel := context.WithCancel(context.Background())
 	newClient := &Client{
-		chann:      make(chan *Wrapper, maxChanSize),
-		handler:    handler,
-		ctx:        ctx,
-		cancelFunc: cancel,
+		chann:           make(chan *Wrapper, maxChanSize),
+		handler:         handler,
+		ctx:             ctx,
+		cancelFunc:      cancel,
+		waitKeyframe:    true,
+		lastKeyframeReq: time.Now(),
+		policy:          f.getClientPolicy(clientID),
+		paused:          f.getClientPaused(clientID),
+		onStart:         onStart,
 	}
+	newClient.filter.Store(f.getClientLayer(clientID))
 
 	f.AddClient(clientID, newClient)
 
+	// new client is holding until keyframe, ask publisher right now
+	f.mutex.RLock()
+	f.requestKeyframe()
+	f.mutex.RUnlock()
+
 	go f.collectData(clientID, newClient)
 }
 
@@ -249,6 +381,11 @@ func (f *Forwarder) collectData(clientID *string, c *Client) {
 			pkg := pkgPool.Get().(*rtp.Packet)
 			pkg.Unmarshal(w.Data)
 			buff.Pkg = pkg
+			buff.Layer = w.Layer
+			buff.Skip = false
+			if filter := c.filter.Load(); filter != nil && w.Layer != nil {
+				buff.Skip = !filter.Apply(pkg, w.Layer)
+			}
 			if err = c.handler(f.getID(), buff); err != nil {
 				f.error(fmt.Sprintf("%s handler err: %v", *clientID, err))
 				return
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/lamhai1401/gologs/logs"
 )
@@ -12,6 +13,191 @@ func (f *Forwarder) getID() string {
 	return f.id
 }
 
+// GetCodec return mimetype of publishing track
+func (f *Forwarder) GetCodec() string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.codec
+}
+
+// SetCodec set mimetype of publishing track, use to detect keyframe
+func (f *Forwarder) SetCodec(codec string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.codec = codec
+}
+
+// SetKeyframeHandler set handler to request keyframe from publisher
+func (f *Forwarder) SetKeyframeHandler(handler func(trackID string)) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.keyframeHandler = handler
+}
+
+// SetAudioLevelID set negotiated id of audio level extension of publishing track
+func (f *Forwarder) SetAudioLevelID(id uint8) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.audioLevelID = id
+}
+
+// SetDependencyDescriptorID set negotiated id of av1 dependency descriptor extension of publishing track, 0 is disable
+func (f *Forwarder) SetDependencyDescriptorID(id uint8) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if id == 0 {
+		f.dependency = nil
+		return
+	}
+	if f.dependency == nil || f.dependency.id != id {
+		f.dependency = NewDependencyParser(id)
+	}
+}
+
+// SetAudioLevelHandler set handler receive audio level of each packet
+func (f *Forwarder) SetAudioLevelHandler(handler func(trackID string, level uint8, voice bool)) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.audioLevelHandler = handler
+}
+
+// SetCacheSize keep size recently forwarded packet, 0 is disable cache
+func (f *Forwarder) SetCacheSize(size int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if size <= 0 {
+		f.cache = nil
+		return
+	}
+	f.cache = NewPacketCache(size)
+}
+
+// GetPacket return raw packet of ssrc/seq in cache
+func (f *Forwarder) GetPacket(ssrc uint32, seq uint16) ([]byte, bool) {
+	f.mutex.RLock()
+	cache := f.cache
+	f.mutex.RUnlock()
+	if cache == nil {
+		return nil, false
+	}
+	return cache.Get(ssrc, seq)
+}
+
+// SetPolicy set default drop policy for new client
+func (f *Forwarder) SetPolicy(policy DropPolicy) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.policy = policy
+}
+
+// SetClientPolicy set drop policy of client, keep for next register of this client
+func (f *Forwarder) SetClientPolicy(clientID *string, policy DropPolicy) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.policies[*clientID] = policy
+	if c := f.clients[*clientID]; c != nil {
+		c.policy = policy
+	}
+}
+
+// GetClientDropped return number of dropped packet of client
+func (f *Forwarder) GetClientDropped(clientID *string) uint64 {
+	if c := f.getClient(clientID); c != nil {
+		return c.Dropped()
+	}
+	return 0
+}
+
+func (f *Forwarder) getClientPolicy(clientID *string) DropPolicy {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	if policy, ok := f.policies[*clientID]; ok {
+		return policy
+	}
+	return f.policy
+}
+
+// SetClientLayer set max svc spatial/temporal layer of client.
+// Negative value is no limit, both negative remove the filter
+func (f *Forwarder) SetClientLayer(clientID *string, spatial, temporal int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	client := f.clients[*clientID]
+
+	if spatial < 0 && temporal < 0 {
+		delete(f.layers, *clientID)
+		if client != nil {
+			client.filter.Store(nil)
+		}
+		return
+	}
+
+	s, t := toLayerID(spatial), toLayerID(temporal)
+	filter := f.layers[*clientID]
+	if filter == nil {
+		filter = NewLayerFilter(s, t)
+		f.layers[*clientID] = filter
+	} else if filter.SetTarget(s, t) {
+		// switch up spatial layer need keyframe
+		f.requestKeyframe()
+	}
+
+	if client != nil {
+		client.filter.Store(filter)
+	}
+}
+
+func (f *Forwarder) getClientLayer(clientID *string) *LayerFilter {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.layers[*clientID]
+}
+
+// SetClientPaused stop or resume forwarding to client, keep for next register of this client.
+// Resumed client wait for the next keyframe
+func (f *Forwarder) SetClientPaused(clientID *string, paused bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if paused {
+		f.paused[*clientID] = true
+	} else {
+		delete(f.paused, *clientID)
+	}
+
+	client := f.clients[*clientID]
+	if client == nil || client.paused == paused {
+		return
+	}
+	client.paused = paused
+	if !paused {
+		client.waitKeyframe = true
+		client.lastKeyframeReq = time.Now()
+		f.requestKeyframe()
+	}
+}
+
+func (f *Forwarder) getClientPaused(clientID *string) bool {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.paused[*clientID]
+}
+
+// deleteClientSettings remove policy, layer filter and pause state of client
+func (f *Forwarder) deleteClientSettings(clientID *string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.policies, *clientID)
+	delete(f.layers, *clientID)
+	delete(f.paused, *clientID)
+}
+
+func toLayerID(layer int) uint8 {
+	if layer < 0 || layer > 0xFF {
+		return 0xFF
+	}
+	return uint8(layer)
+}
+
 func (f *Forwarder) checkClose() bool {
 	f.mutex.RLock()
 	defer f.mutex.RUnlock()
@@ -34,7 +34,13 @@ type ForwarderMannager struct {
 	hub           chan *FwdmAction // dispatch all data
 	dataTimeChann chan *ClientDataTime
 	dataTime      map[string]int64
-	mutex         sync.RWMutex
+	// keyframeHandler set to every forwarder for request keyframe
+	keyframeHandler func(trackID string)
+	// audioLevelHandler set to every forwarder for receive audio level
+	audioLevelHandler func(trackID string, level uint8, voice bool)
+	policy            DropPolicy // default drop policy of forwarder
+	cacheSize         int        // packet cache size of forwarder, 0 is disable
+	mutex             sync.RWMutex
 }
 
 // NewForwarderMannager create audio or video forwader
@@ -46,6 +52,7 @@ func NewForwarderMannager(id string) Fwdm {
 		hub:           make(chan *FwdmAction, maxChanSize),
 		dataTimeChann: make(chan *ClientDataTime, maxChanSize),
 		dataTime:      make(map[string]int64),
+		policy:        PolicyBlock,
 		isClosed:      false,
 	}
 
@@ -170,11 +177,17 @@ func (f *ForwarderMannager) UnregisterAll(peerConnectionID string) {
 
 // Register regis a client id to specific forwarder
 func (f *ForwarderMannager) Register(trackID string, clientID string, handler func(trackID string, wrapper *Wrapper) error) {
+	f.RegisterWithStart(trackID, clientID, handler, nil)
+}
+
+// RegisterWithStart regis a client id to specific forwarder, onStart is called when client start receive data
+func (f *ForwarderMannager) RegisterWithStart(trackID string, clientID string, handler func(trackID string, wrapper *Wrapper) error, onStart func()) {
 	newAction := &FwdmAction{
 		Action: Action{
 			id: &trackID,
 			client: &Client{
 				handler: handler,
+				onStart: onStart,
 			},
 		},
 		pcID: &clientID,
@@ -207,6 +220,10 @@ func (f *ForwarderMannager) addNewForwarder(fwdID *string, result chan *Forwarde
 	}
 	// create new
 	newForwader := NewForwarder(*fwdID, f.dataTimeChann)
+	newForwader.SetKeyframeHandler(f.getKeyframeHandler())
+	newForwader.SetAudioLevelHandler(f.getAudioLevelHandler())
+	newForwader.SetPolicy(f.getPolicy())
+	newForwader.SetCacheSize(f.getCacheSize())
 	f.setForwarder(fwdID, newForwader)
 	logs.Info(fmt.Sprintf("Add New %s forwarder successful", *fwdID))
 	result <- newForwader
@@ -237,6 +254,7 @@ func (f *ForwarderMannager) forward(msg *FwdmAction) {
 
 func (f *ForwarderMannager) unregister(trackID, pcID *string) {
 	if forwardfer := f.getForwarder(trackID); forwardfer != nil {
+		forwardfer.deleteClientSettings(pcID)
 		forwardfer.closeClient(pcID)
 	}
 }
@@ -249,7 +267,84 @@ func (f *ForwarderMannager) register(action *FwdmAction) {
 	if forwardfer == nil {
 		forwardfer = f.AddNewForwarder(*action.id) // TODO check maybe stuck here
 	}
-	forwardfer.Register(action.pcID, action.client.handler)
+	forwardfer.RegisterWithStart(action.pcID, action.client.handler, action.client.onStart)
+}
+
+// SetKeyframeHandler set handler to request keyframe for all forwarder
+func (f *ForwarderMannager) SetKeyframeHandler(handler func(trackID string)) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.keyframeHandler = handler
+	for _, fwd := range f.forwadrders {
+		fwd.SetKeyframeHandler(handler)
+	}
+}
+
+// SetAudioLevelHandler set handler receive audio level for all forwarder
+func (f *ForwarderMannager) SetAudioLevelHandler(handler func(trackID string, level uint8, voice bool)) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.audioLevelHandler = handler
+	for _, fwd := range f.forwadrders {
+		fwd.SetAudioLevelHandler(handler)
+	}
+}
+
+// SetPolicy set default drop policy for all forwarder
+func (f *ForwarderMannager) SetPolicy(policy DropPolicy) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.policy = policy
+	for _, fwd := range f.forwadrders {
+		fwd.SetPolicy(policy)
+	}
+}
+
+// SetCacheSize set packet cache size for all forwarder, 0 is disable
+func (f *ForwarderMannager) SetCacheSize(size int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.cacheSize = size
+	for _, fwd := range f.forwadrders {
+		fwd.SetCacheSize(size)
+	}
+}
+
+// GetPacket return cached raw packet of ssrc/seq in trackID forwarder
+func (f *ForwarderMannager) GetPacket(trackID string, ssrc uint32, seq uint16) ([]byte, bool) {
+	if fwd := f.getForwarder(&trackID); fwd != nil {
+		return fwd.GetPacket(ssrc, seq)
+	}
+	return nil, false
+}
+
+// SetClientPolicy set drop policy of pcID in trackID forwarder, do nothing if trackID has no forwarder
+func (f *ForwarderMannager) SetClientPolicy(trackID, pcID string, policy DropPolicy) {
+	if fwd := f.getForwarder(&trackID); fwd != nil {
+		fwd.SetClientPolicy(&pcID, policy)
+	}
+}
+
+// SetClientLayer set max svc layer of pcID in trackID forwarder, do nothing if trackID has no forwarder
+func (f *ForwarderMannager) SetClientLayer(trackID, pcID string, spatial, temporal int) {
+	if fwd := f.getForwarder(&trackID); fwd != nil {
+		fwd.SetClientLayer(&pcID, spatial, temporal)
+	}
+}
+
+// SetClientPaused stop or resume forwarding to pcID in trackID forwarder, do nothing if trackID has no forwarder
+func (f *ForwarderMannager) SetClientPaused(trackID, pcID string, paused bool) {
+	if fwd := f.getForwarder(&trackID); fwd != nil {
+		fwd.SetClientPaused(&pcID, paused)
+	}
+}
+
+// GetClientDropped return number of dropped packet of pcID in trackID forwarder
+func (f *ForwarderMannager) GetClientDropped(trackID, pcID string) uint64 {
+	if fwd := f.getForwarder(&trackID); fwd != nil {
+		return fwd.GetClientDropped(&pcID)
+	}
+	return 0
 }
 
 // GetLastTimeReceive linter
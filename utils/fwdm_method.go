@@ -67,3 +67,27 @@ func (f *ForwarderMannager) deleteDataTime(fwdID string) {
 	delete(f.dataTime, fwdID)
 	f.mutex.Unlock()
 }
+
+func (f *ForwarderMannager) getAudioLevelHandler() func(trackID string, level uint8, voice bool) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.audioLevelHandler
+}
+
+func (f *ForwarderMannager) getKeyframeHandler() func(trackID string) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.keyframeHandler
+}
+
+func (f *ForwarderMannager) getPolicy() DropPolicy {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.policy
+}
+
+func (f *ForwarderMannager) getCacheSize() int {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.cacheSize
+}
@@ -5,6 +5,7 @@ type Fwdm interface {
 	UnregisterAll(peerConnectionID string) // unregister of fwd with input peer connection id
 	// RegisterAll(clientID string, handler func(trackID string, wrapper *Wrapper) error)
 	Register(fwdID string, clientID string, handler func(trackID string, wrapper *Wrapper) error)
+	RegisterWithStart(fwdID string, clientID string, handler func(trackID string, wrapper *Wrapper) error, onStart func())
 	Unregister(trackID, pcID *string)
 	AddNewForwarder(id string) *Forwarder
 	RemoveForwarder(id string)
@@ -15,4 +16,13 @@ type Fwdm interface {
 	GetClient(trackID, pcID *string) chan *Wrapper
 	GetLastTimeReceive() map[string]int64
 	GetLastTimeReceiveBy(trackID string) int64
+	SetKeyframeHandler(handler func(trackID string))
+	SetAudioLevelHandler(handler func(trackID string, level uint8, voice bool))
+	SetPolicy(policy DropPolicy)
+	SetClientPolicy(trackID, pcID string, policy DropPolicy)
+	GetClientDropped(trackID, pcID string) uint64
+	SetClientLayer(trackID, pcID string, spatial, temporal int)
+	SetClientPaused(trackID, pcID string, paused bool)
+	SetCacheSize(size int)
+	GetPacket(trackID string, ssrc uint32, seq uint16) ([]byte, bool)
 }
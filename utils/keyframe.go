@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/pion/rtp/codecs"
+)
+
+// h264 nal unit types
+const (
+	h264NaluIDR   = 5
+	h264NaluSPS   = 7
+	h264NaluSTAPA = 24
+	h264NaluFUA   = 28
+)
+
+// h265 nal unit types
+const (
+	h265NaluIRAPStart = 16 // BLA_W_LP, first intra random access point
+	h265NaluIRAPEnd   = 23 // RSV_IRAP_VCL23, last intra random access point
+	h265NaluVPS       = 32
+	h265NaluSPS       = 33
+	h265NaluAP        = 48
+	h265NaluFU        = 49
+)
+
+// av1 aggregation header bit and obu type
+const (
+	av1AggregationZ      = 0x80 // first obu element is continuation of previous packet
+	av1AggregationN      = 0x08 // first packet of a coded video sequence
+	av1OBUSequenceHeader = 1
+)
+
+// NeedKeyframe return true if this mimetype is a video codec that we can gate on keyframe
+func NeedKeyframe(mimeType string) bool {
+	switch strings.ToLower(mimeType) {
+	case strings.ToLower(MimeTypeVP8),
+		strings.ToLower(MimeTypeVP9),
+		strings.ToLower(MimeTypeH264),
+		strings.ToLower(MimeTypeH265),
+		strings.ToLower(MimeTypeAV1):
+		return true
+	default:
+		return false
+	}
+}
+
+// IsKeyframe check rtp payload is the first packet of a keyframe
+// codec is the track mimetype. Unknown codec always return false
+func IsKeyframe(mimeType string, payload []byte) bool {
+	switch strings.ToLower(mimeType) {
+	case strings.ToLower(MimeTypeVP8):
+		return isVP8Keyframe(payload)
+	case strings.ToLower(MimeTypeVP9):
+		return isVP9Keyframe(payload)
+	case strings.ToLower(MimeTypeH264):
+		return isH264Keyframe(payload)
+	case strings.ToLower(MimeTypeH265):
+		return isH265Keyframe(payload)
+	case strings.ToLower(MimeTypeAV1):
+		return isAV1Keyframe(payload)
+	default:
+		return false
+	}
+}
+
+// isVP8Keyframe start of partition 0 and P bit of vp8 payload header is 0
+func isVP8Keyframe(payload []byte) bool {
+	vp8 := &codecs.VP8Packet{}
+	if _, err := vp8.Unmarshal(payload); err != nil {
+		return false
+	}
+	if vp8.S != 1 || vp8.PID != 0 || len(vp8.Payload) == 0 {
+		return false
+	}
+	return vp8.Payload[0]&0x01 == 0
+}
+
+// isVP9Keyframe start of a not inter-picture predicted frame on the base spatial layer
+func isVP9Keyframe(payload []byte) bool {
+	vp9 := &codecs.VP9Packet{}
+	if _, err := vp9.Unmarshal(payload); err != nil {
+		return false
+	}
+	return vp9.B && !vp9.P && vp9.SID == 0
+}
+
+// isH264Keyframe find IDR or SPS nal in single, STAP-A or start of FU-A
+func isH264Keyframe(payload []byte) bool {
+	if len(payload) < 1 {
+		return false
+	}
+
+	switch naluType := payload[0] & 0x1F; naluType {
+	case h264NaluIDR, h264NaluSPS:
+		return true
+	case h264NaluSTAPA:
+		offset := 1
+		for offset+2 < len(payload) {
+			size := int(payload[offset])<<8 | int(payload[offset+1])
+			offset += 2
+			if size == 0 || offset+size > len(payload) {
+				return false
+			}
+			switch payload[offset] & 0x1F {
+			case h264NaluIDR, h264NaluSPS:
+				return true
+			}
+			offset += size
+		}
+		return false
+	case h264NaluFUA:
+		if len(payload) < 2 {
+			return false
+		}
+		// start bit and inner nal type
+		return payload[1]&0x80 != 0 && payload[1]&0x1F == h264NaluIDR
+	default:
+		return false
+	}
+}
+
+// isH265Keyframe find IRAP, VPS or SPS nal in single, aggregation packet or start of fragmentation unit.
+// DONL is not supported, sprop-max-don-diff is always 0 in webrtc
+func isH265Keyframe(payload []byte) bool {
+	if len(payload) < 2 {
+		return false
+	}
+
+	switch naluType := h265NaluType(payload[0]); naluType {
+	case h265NaluAP:
+		offset := 2
+		for offset+2 < len(payload) {
+			size := int(payload[offset])<<8 | int(payload[offset+1])
+			offset += 2
+			if size == 0 || offset+size > len(payload) {
+				return false
+			}
+			if isH265KeyNalu(h265NaluType(payload[offset])) {
+				return true
+			}
+			offset += size
+		}
+		return false
+	case h265NaluFU:
+		if len(payload) < 3 {
+			return false
+		}
+		// start bit and inner nal type
+		return payload[2]&0x80 != 0 && isH265KeyNalu(payload[2]&0x3F)
+	default:
+		return isH265KeyNalu(naluType)
+	}
+}
+
+// h265NaluType read type of the first byte of 2 byte nal header
+func h265NaluType(header byte) byte {
+	return (header >> 1) & 0x3F
+}
+
+func isH265KeyNalu(naluType byte) bool {
+	return (naluType >= h265NaluIRAPStart && naluType <= h265NaluIRAPEnd) ||
+		naluType == h265NaluVPS || naluType == h265NaluSPS
+}
+
+// isAV1Keyframe N bit of aggregation header start a new coded video sequence,
+// or a sequence header obu is in the packet
+func isAV1Keyframe(payload []byte) bool {
+	if len(payload) < 2 {
+		return false
+	}
+	header := payload[0]
+	if header&av1AggregationN != 0 {
+		return true
+	}
+
+	// W is number of obu element, 0 is every element has a length field
+	count := int(header>>4) & 0x03
+	offset := 1
+	for i := 0; offset < len(payload); i++ {
+		size := len(payload) - offset
+		// last element of W count has no length field
+		if count == 0 || i < count-1 {
+			length, n := readLeb128(payload[offset:])
+			if n == 0 {
+				return false
+			}
+			offset += n
+			size = int(length)
+		}
+		if size <= 0 || offset+size > len(payload) {
+			return false
+		}
+		// continuation of obu from previous packet has no obu header
+		if (i > 0 || header&av1AggregationZ == 0) && (payload[offset]>>3)&0x0F == av1OBUSequenceHeader {
+			return true
+		}
+		offset += size
+		if count != 0 && i == count-1 {
+			return false
+		}
+	}
+	return false
+}
+
+// readLeb128 return value and number of byte read, 0 byte read if data is invalid
+func readLeb128(data []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < len(data) && i < 8; i++ {
+		value |= uint64(data[i]&0x7F) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
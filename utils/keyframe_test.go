@@ -0,0 +1,77 @@
+package utils
+
+import "testing"
+
+func TestIsKeyframe(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		payload  []byte
+		want     bool
+	}{
+		{"vp8 keyframe", MimeTypeVP8, []byte{0x10, 0x00, 0x9d, 0x01, 0x2a}, true},
+		{"vp8 interframe", MimeTypeVP8, []byte{0x10, 0x01, 0x9d, 0x01, 0x2a}, false},
+		{"vp8 not start of partition", MimeTypeVP8, []byte{0x00, 0x00, 0x9d, 0x01, 0x2a}, false},
+		{"vp8 truncated", MimeTypeVP8, []byte{0x10}, false},
+		{"vp8 empty", MimeTypeVP8, nil, false},
+
+		{"vp9 keyframe", MimeTypeVP9, []byte{0x08, 0x00}, true},
+		{"vp9 inter predicted", MimeTypeVP9, []byte{0x48, 0x00}, false},
+		{"vp9 not begin of frame", MimeTypeVP9, []byte{0x00, 0x00}, false},
+		{"vp9 empty", MimeTypeVP9, nil, false},
+
+		{"h264 idr", MimeTypeH264, []byte{0x65, 0x88}, true},
+		{"h264 sps", MimeTypeH264, []byte{0x67, 0x42}, true},
+		{"h264 non idr", MimeTypeH264, []byte{0x41, 0x9a}, false},
+		{"h264 stap-a with sps", MimeTypeH264, []byte{0x78, 0x00, 0x02, 0x67, 0x42, 0x00, 0x02, 0x68, 0xce}, true},
+		{"h264 stap-a without key nal", MimeTypeH264, []byte{0x78, 0x00, 0x02, 0x41, 0x9a}, false},
+		{"h264 stap-a truncated", MimeTypeH264, []byte{0x78, 0x00, 0x09, 0x67, 0x42}, false},
+		{"h264 fu-a start of idr", MimeTypeH264, []byte{0x7c, 0x85, 0x88}, true},
+		{"h264 fu-a middle of idr", MimeTypeH264, []byte{0x7c, 0x05, 0x88}, false},
+		{"h264 fu-a truncated", MimeTypeH264, []byte{0x7c}, false},
+		{"h264 empty", MimeTypeH264, nil, false},
+
+		{"h265 idr", MimeTypeH265, []byte{0x26, 0x01, 0xaf}, true},
+		{"h265 cra", MimeTypeH265, []byte{0x2a, 0x01, 0xaf}, true},
+		{"h265 vps", MimeTypeH265, []byte{0x40, 0x01, 0x0c}, true},
+		{"h265 trail", MimeTypeH265, []byte{0x02, 0x01, 0xd0}, false},
+		{"h265 ap with vps", MimeTypeH265, []byte{0x60, 0x01, 0x00, 0x02, 0x40, 0x01, 0x00, 0x02, 0x42, 0x01}, true},
+		{"h265 ap without key nal", MimeTypeH265, []byte{0x60, 0x01, 0x00, 0x02, 0x02, 0x01}, false},
+		{"h265 ap truncated", MimeTypeH265, []byte{0x60, 0x01, 0x00, 0x09, 0x40, 0x01}, false},
+		{"h265 fu start of idr", MimeTypeH265, []byte{0x62, 0x01, 0x93, 0xaf}, true},
+		{"h265 fu middle of idr", MimeTypeH265, []byte{0x62, 0x01, 0x13, 0xaf}, false},
+		{"h265 fu truncated", MimeTypeH265, []byte{0x62, 0x01}, false},
+		{"h265 truncated", MimeTypeH265, []byte{0x26}, false},
+
+		{"av1 new coded video sequence", MimeTypeAV1, []byte{0x08, 0x0a, 0x0b}, true},
+		{"av1 sequence header with length", MimeTypeAV1, []byte{0x00, 0x02, 0x08, 0x00}, true},
+		{"av1 sequence header last of W elements", MimeTypeAV1, []byte{0x20, 0x01, 0x12, 0x08, 0x00}, true},
+		{"av1 frame only", MimeTypeAV1, []byte{0x20, 0x01, 0x12, 0x30, 0x00}, false},
+		{"av1 continuation is not an obu header", MimeTypeAV1, []byte{0x90, 0x08, 0x00}, false},
+		{"av1 length over payload", MimeTypeAV1, []byte{0x00, 0x05, 0x08}, false},
+		{"av1 truncated leb128", MimeTypeAV1, []byte{0x00, 0x80}, false},
+		{"av1 truncated", MimeTypeAV1, []byte{0x00}, false},
+
+		{"mimetype is case insensitive", "video/vp8", []byte{0x10, 0x00, 0x9d, 0x01, 0x2a}, true},
+		{"audio is never keyframe", "audio/opus", []byte{0x10, 0x00}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsKeyframe(tt.mimeType, tt.payload); got != tt.want {
+				t.Errorf("IsKeyframe(%s, %x) = %v, want %v", tt.mimeType, tt.payload, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNeedKeyframe(t *testing.T) {
+	for _, mimeType := range []string{MimeTypeVP8, MimeTypeVP9, MimeTypeH264, MimeTypeH265, MimeTypeAV1} {
+		if !NeedKeyframe(mimeType) {
+			t.Errorf("NeedKeyframe(%s) = false", mimeType)
+		}
+	}
+	if NeedKeyframe("audio/opus") {
+		t.Error("NeedKeyframe(audio/opus) = true")
+	}
+}
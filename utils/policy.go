@@ -0,0 +1,63 @@
+package utils
+
+import "sync/atomic"
+
+// DropPolicy decide what fwd do when client channel is full
+type DropPolicy string
+
+const (
+	// PolicyBlock wait until client read data. Slow client stall all client of fwd
+	PolicyBlock DropPolicy = "block"
+	// PolicyDropOldest drop the oldest packet in client queue
+	PolicyDropOldest DropPolicy = "drop_oldest"
+	// PolicyDropNewest drop the incoming packet
+	PolicyDropNewest DropPolicy = "drop_newest"
+	// PolicyDropUntilKeyframe drop every packet until the next keyframe, use for video
+	PolicyDropUntilKeyframe DropPolicy = "drop_until_keyframe"
+)
+
+// send push wrapper to client with client policy
+func (f *Forwarder) send(client *Client, wrapper *Wrapper) {
+	switch client.policy {
+	case PolicyDropNewest:
+		select {
+		case client.chann <- wrapper:
+		default:
+			client.drop()
+		}
+	case PolicyDropOldest:
+		for {
+			select {
+			case client.chann <- wrapper:
+				return
+			default:
+			}
+			select {
+			case <-client.chann:
+				client.drop()
+			default:
+			}
+		}
+	case PolicyDropUntilKeyframe:
+		select {
+		case client.chann <- wrapper:
+		default:
+			// hold client until next keyframe
+			client.drop()
+			client.waitKeyframe = true
+			client.overflow = true
+			f.retryKeyframe(client)
+		}
+	default:
+		client.chann <- wrapper
+	}
+}
+
+func (c *Client) drop() {
+	atomic.AddUint64(&c.dropped, 1)
+}
+
+// Dropped return number of packet was dropped by client policy
+func (c *Client) Dropped() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
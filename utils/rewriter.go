@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+)
+
+// historySize number of output packet remember to answer NACK
+const historySize = 1024
+
+// History map output packet back to its source packet
+type History struct {
+	valid    bool
+	OutSeq   uint16 // output sequence number
+	SrcSeq   uint16 // source sequence number
+	SSRC     uint32 // source ssrc
+	TSOffset uint32 // timestamp offset apply to source packet
+	Marker   bool   // marker bit of output packet
+}
+
+// Rewriter keep sequence number and timestamp continuous for one subscriber local track
+// when the source of this local track change (switch trackID or publisher reconnect with new ssrc).
+// SSRC is stable because TrackLocalStaticRTP overwrite it with the sender ssrc
+type Rewriter struct {
+	clockRate uint32
+	started   bool
+	ssrc      uint32 // current source ssrc
+	dropSSRC  uint32 // old source ssrc, late packet of this ssrc will be dropped
+	hasDrop   bool
+	pending   bool // resync on next packet even with the same source
+	switching bool // source is switching, old source ssrc may come back as the new one
+	seqOffset uint16
+	tsOffset  uint32
+	maxSeq    uint16 // highest source sequence number of current source forwarded or skipped
+	skipSeq   uint16 // source sequence number of last skipped packet
+	hasSkip   bool
+	lastSeq   uint16    // last output sequence number
+	lastTS    uint32    // last output timestamp
+	lastTime  time.Time // last time output packet
+	history   []History
+	mutex     sync.Mutex
+}
+
+// NewRewriter linter
+func NewRewriter(clockRate uint32) *Rewriter {
+	return &Rewriter{
+		clockRate: clockRate,
+		history:   make([]History, historySize),
+	}
+}
+
+// Switch mark source is changing, the next source continue from the last output packet.
+// Current source is dropped once packet of a new ssrc arrive, so switching back to
+// the source dropped by a previous switch is allowed
+func (r *Rewriter) Switch() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.started {
+		return
+	}
+	r.pending = true
+	r.switching = true
+}
+
+// Resync continue next packet right after the last output packet.
+// Use when forwarding of the same source was paused
+func (r *Rewriter) Resync() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.started {
+		r.pending = true
+	}
+}
+
+// Rewrite modify header of packet in place, return false if packet must be dropped
+func (r *Rewriter) Rewrite(pkg *rtp.Packet) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	switch {
+	case !r.started:
+		r.started = true
+		r.ssrc = pkg.SSRC
+		r.lastSeq = pkg.SequenceNumber - 1
+		r.maxSeq = pkg.SequenceNumber - 1
+	case r.hasDrop && pkg.SSRC == r.dropSSRC && !r.switching:
+		return false
+	case r.pending || pkg.SSRC != r.ssrc:
+		r.resync(pkg, now)
+	case r.hasSkip && isNewer(r.skipSeq, pkg.SequenceNumber):
+		// late packet sent before the skip, offset of its number was already compacted
+		return false
+	}
+
+	srcSeq := pkg.SequenceNumber
+	pkg.SequenceNumber += r.seqOffset
+	pkg.Timestamp += r.tsOffset
+	r.history[int(pkg.SequenceNumber)%historySize] = History{
+		valid:    true,
+		OutSeq:   pkg.SequenceNumber,
+		SrcSeq:   srcSeq,
+		SSRC:     pkg.SSRC,
+		TSOffset: r.tsOffset,
+		Marker:   pkg.Marker,
+	}
+
+	if isNewer(srcSeq, r.maxSeq) {
+		r.maxSeq = srcSeq
+	}
+	// only move forward, out of order packet keep its own number
+	if isNewer(pkg.SequenceNumber, r.lastSeq) {
+		r.lastSeq = pkg.SequenceNumber
+		r.lastTS = pkg.Timestamp
+		r.lastTime = now
+	}
+	return true
+}
+
+// Lookup return source packet info of output sequence number
+func (r *Rewriter) Lookup(outSeq uint16) (History, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	h := r.history[int(outSeq)%historySize]
+	if !h.valid || h.OutSeq != outSeq {
+		return h, false
+	}
+	return h, true
+}
+
+// Skip packet of current source was filtered, next packet continue without gap.
+// Only packet newer than every forwarded packet is compacted, late filtered packet leave a gap
+func (r *Rewriter) Skip(pkg *rtp.Packet) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.started || pkg.SSRC != r.ssrc || r.pending || !isNewer(pkg.SequenceNumber, r.maxSeq) {
+		return
+	}
+	r.seqOffset--
+	r.maxSeq = pkg.SequenceNumber
+	r.skipSeq = pkg.SequenceNumber
+	r.hasSkip = true
+}
+
+// resync continue new source right after the last output packet
+func (r *Rewriter) resync(pkg *rtp.Packet, now time.Time) {
+	r.pending = false
+	if pkg.SSRC != r.ssrc {
+		r.dropSSRC = r.ssrc
+		r.hasDrop = true
+		r.switching = false
+		r.ssrc = pkg.SSRC
+	}
+
+	gap := uint32(now.Sub(r.lastTime).Seconds() * float64(r.clockRate))
+	if gap == 0 {
+		gap = 1
+	}
+
+	r.hasSkip = false
+	r.maxSeq = pkg.SequenceNumber - 1
+	r.seqOffset = r.lastSeq + 1 - pkg.SequenceNumber
+	r.tsOffset = r.lastTS + gap - pkg.Timestamp
+}
+
+// isNewer return true if sequence number a is after b
+func isNewer(a, b uint16) bool {
+	diff := a - b
+	return diff != 0 && diff < 0x8000
+}
@@ -61,6 +61,14 @@ func CutID(id string) (string, string) {
 	return clientID, teacherID
 }
 
+// LayerTrackID return fwd id of a simulcast layer, empty rid return trackID
+func LayerTrackID(trackID, rid string) string {
+	if rid == "" {
+		return trackID
+	}
+	return fmt.Sprintf("%s_%s", trackID, rid)
+}
+
 // MergeID merge clientID, peerID to id
 func MergeID(clientID string, peerID string) string {
 	return fmt.Sprintf("%s_%s", clientID, peerID)
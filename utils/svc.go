@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"sync"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+)
+
+// Layer svc layer info of a vp9 packet or of av1 dependency descriptor
+type Layer struct {
+	SID       uint8 // spatial layer id
+	TID       uint8 // temporal layer id
+	Start     bool  // start of layer frame
+	End       bool  // end of layer frame
+	Switch    bool  // switching up point of temporal layer
+	Predicted bool  // inter-picture predicted
+}
+
+// ParseVP9Layer parse vp9 payload descriptor, return nil if payload is invalid
+func ParseVP9Layer(payload []byte) *Layer {
+	vp9 := &codecs.VP9Packet{}
+	if _, err := vp9.Unmarshal(payload); err != nil {
+		return nil
+	}
+	return &Layer{
+		SID:       vp9.SID,
+		TID:       vp9.TID,
+		Start:     vp9.B,
+		End:       vp9.E,
+		Switch:    vp9.U,
+		Predicted: vp9.P,
+	}
+}
+
+// Keyframe return true if this is start of a keyframe
+func (l *Layer) Keyframe() bool {
+	return l.Start && !l.Predicted && l.SID == 0
+}
+
+// LayerFilter choose max spatial and temporal layer of one client.
+// Spatial up switch wait for keyframe, temporal up switch wait for switching point
+type LayerFilter struct {
+	targetS  uint8
+	targetT  uint8
+	currentS uint8
+	currentT uint8
+	mutex    sync.Mutex
+}
+
+// NewLayerFilter linter
+func NewLayerFilter(spatial, temporal uint8) *LayerFilter {
+	return &LayerFilter{
+		targetS:  spatial,
+		targetT:  temporal,
+		currentS: spatial,
+		currentT: temporal,
+	}
+}
+
+// SetTarget change max layer, return true if need a keyframe to switch up
+func (l *LayerFilter) SetTarget(spatial, temporal uint8) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.targetS = spatial
+	l.targetT = temporal
+	return l.targetS > l.currentS
+}
+
+// Apply return false if packet must be dropped.
+// Marker bit is set at the end of the highest forwarded spatial layer
+func (l *LayerFilter) Apply(pkg *rtp.Packet, layer *Layer) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// change layer at frame boundary of the base layer
+	if layer.Start && layer.SID == 0 {
+		if layer.Keyframe() {
+			l.currentS = l.targetS
+			l.currentT = l.targetT
+		} else {
+			if l.targetS < l.currentS {
+				l.currentS = l.targetS
+			}
+			if l.targetT < l.currentT {
+				l.currentT = l.targetT
+			}
+		}
+	}
+
+	if layer.Switch && layer.TID > l.currentT && layer.TID <= l.targetT {
+		l.currentT = layer.TID
+	}
+
+	if layer.SID > l.currentS || layer.TID > l.currentT {
+		return false
+	}
+
+	if layer.End && layer.SID == l.currentS {
+		pkg.Marker = true
+	}
+	return true
+}
@@ -0,0 +1,337 @@
+package whip
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/spgnk/rtc/auth"
+	"github.com/spgnk/rtc/peer"
+	"github.com/spgnk/rtc/token"
+	"github.com/spgnk/rtc/worker"
+)
+
+const (
+	// MimeTypeSDP content type of offer and answer
+	MimeTypeSDP = "application/sdp"
+	// MimeTypeTrickleICE content type of PATCH body
+	MimeTypeTrickleICE = "application/trickle-ice-sdpfrag"
+
+	maxBodySize    = 1 << 20         // max size of sdp body
+	gatherTimeout  = 5 * time.Second // max duration wait for ICE gathering before answer
+	signalIDQuery  = "signal_id"
+	locationFormat = "%s/%s"
+)
+
+// session is a peer connection created by a POST, identified by pcID in Location
+type session struct {
+	signalID string
+	pcID     string
+	cookieID string
+	claims   *auth.Claims // claims of POST token, released with the session
+}
+
+// base is shared part of WHIP and WHEP handler
+type base struct {
+	worker     worker.Worker
+	prefix     string                // url path of endpoint, resource is prefix/pcID
+	turnConfig *webrtc.Configuration // ice server of new peer
+	sessions   map[string]*session   // save pcID - session
+	tokens     *token.Manager        // nil accept request without token
+	mutex      sync.RWMutex
+}
+
+func newBase(w worker.Worker, prefix string, turnConfig *webrtc.Configuration) base {
+	if turnConfig == nil {
+		turnConfig = &webrtc.Configuration{}
+	}
+	return base{
+		worker:     w,
+		prefix:     strings.TrimSuffix(prefix, "/"),
+		turnConfig: turnConfig,
+		sessions:   make(map[string]*session),
+	}
+}
+
+// SetTokenManager require a valid token on every request, signalID of peer is taken from token claims.
+// Worker should use authorizer of m to enforce claims on media
+func (b *base) SetTokenManager(m *token.Manager) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.tokens = m
+}
+
+func (b *base) getTokenManager() *token.Manager {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.tokens
+}
+
+// authenticate return claims of request token and save it for a new session, nil claims if no token manager is set.
+// Rejected request is answered 401 and false is returned. Claims must be released if no session is created
+func (b *base) authenticate(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	return b.checkToken(w, r, true)
+}
+
+// verify is authenticate without saving claims, for request on an existing session
+func (b *base) verify(w http.ResponseWriter, r *http.Request) (*auth.Claims, bool) {
+	return b.checkToken(w, r, false)
+}
+
+func (b *base) checkToken(w http.ResponseWriter, r *http.Request, save bool) (*auth.Claims, bool) {
+	m := b.getTokenManager()
+	if m == nil {
+		return nil, true
+	}
+	verify := m.Verify
+	if save {
+		verify = m.Authenticate
+	}
+	claims, err := verify(token.FromRequest(r))
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+	return claims, true
+}
+
+// authorizeTracks check action of claims on every trackID before any peer is created
+func (b *base) authorizeTracks(claims *auth.Claims, action, kind string, trackIDs []string) error {
+	m := b.getTokenManager()
+	if claims == nil || m == nil || m.GetAuthorizer() == nil {
+		return nil
+	}
+	authorizer := m.GetAuthorizer()
+	for _, trackID := range trackIDs {
+		var err error
+		if action == auth.ActionPublish {
+			err = authorizer.CanPublish(claims.SignalID, "", trackID, kind)
+		} else {
+			err = authorizer.CanSubscribe(claims.SignalID, "", trackID, kind)
+		}
+		if err != nil {
+			return auth.NewError(action, claims.SignalID, "", trackID, err)
+		}
+	}
+	return nil
+}
+
+// release claims saved by authenticate, nil claims do nothing
+func (b *base) release(claims *auth.Claims) {
+	if m := b.getTokenManager(); claims != nil && m != nil {
+		m.Release(claims.SignalID)
+	}
+}
+
+// applyClaims fill Allow flag of configs from claims
+func (b *base) applyClaims(claims *auth.Claims, configs *peer.Configs) {
+	if m := b.getTokenManager(); claims != nil && m != nil {
+		m.ApplyConfigs(claims, configs)
+	}
+}
+
+func (b *base) setSession(s *session) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.sessions[s.pcID] = s
+}
+
+func (b *base) getSession(pcID string) *session {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.sessions[pcID]
+}
+
+// deleteSession return false if pcID has no session
+func (b *base) deleteSession(pcID string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, has := b.sessions[pcID]; !has {
+		return false
+	}
+	delete(b.sessions, pcID)
+	return true
+}
+
+// resourceID return pcID of resource url, empty if url is the endpoint
+func (b *base) resourceID(r *http.Request) string {
+	id := strings.TrimPrefix(r.URL.Path, b.prefix)
+	return strings.Trim(id, "/")
+}
+
+func (b *base) location(pcID string) string {
+	return fmt.Sprintf(locationFormat, b.prefix, pcID)
+}
+
+// readOffer return sdp offer in request body
+func readOffer(r *http.Request) (string, int, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), MimeTypeSDP) {
+		return "", http.StatusUnsupportedMediaType, fmt.Errorf("content type must be %s", MimeTypeSDP)
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		return "", http.StatusBadRequest, err
+	}
+	if len(body) == 0 {
+		return "", http.StatusBadRequest, fmt.Errorf("offer is empty")
+	}
+	return string(body), http.StatusOK, nil
+}
+
+// answer set offer to p and return the answer with all gathered candidate
+func answer(ctx context.Context, p *peer.Peer, offer string) (string, error) {
+	gathered := p.GatheringCompletePromise()
+	if err := p.AddOffer(&webrtc.SessionDescription{
+		Type: webrtc.SDPTypeOffer,
+		SDP:  offer,
+	}); err != nil {
+		return "", err
+	}
+
+	select {
+	case <-gathered:
+	case <-time.After(gatherTimeout):
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	desc, err := p.GetLocalDescription()
+	if err != nil {
+		return "", err
+	}
+	if desc == nil {
+		return "", fmt.Errorf("local description is nil")
+	}
+	return desc.SDP, nil
+}
+
+// writeAnswer respond 201 with sdp answer and resource location
+func (b *base) writeAnswer(w http.ResponseWriter, pcID, sdp string) {
+	w.Header().Set("Content-Type", MimeTypeSDP)
+	w.Header().Set("Location", b.location(pcID))
+	w.WriteHeader(http.StatusCreated)
+	_, _ = io.WriteString(w, sdp)
+}
+
+// handleOptions answer pre-flight and endpoint discovery
+func (b *base) handleOptions(w http.ResponseWriter) {
+	w.Header().Set("Accept-Post", MimeTypeSDP)
+	w.Header().Set("Accept-Patch", MimeTypeTrickleICE)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePatch add trickle ICE candidate of client to the session peer
+func (b *base) handlePatch(w http.ResponseWriter, r *http.Request, s *session) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), MimeTypeTrickleICE) {
+		http.Error(w, fmt.Sprintf("content type must be %s", MimeTypeTrickleICE), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	p, err := b.worker.GetConnection(&s.signalID, &s.pcID)
+	if err != nil || p == nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+
+	var mid string
+	scanner := bufio.NewScanner(io.LimitReader(r.Body, maxBodySize))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "a=mid:"):
+			mid = strings.TrimPrefix(line, "a=mid:")
+		case strings.HasPrefix(line, "a=candidate:"):
+			candidate := &webrtc.ICECandidateInit{
+				Candidate: strings.TrimPrefix(line, "a="),
+			}
+			if mid != "" {
+				sdpMid := mid
+				candidate.SDPMid = &sdpMid
+			}
+			if err := p.AddICECandidate(candidate); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDelete close the session peer
+func (b *base) handleDelete(w http.ResponseWriter, s *session) {
+	b.closeSession(s)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (b *base) closeSession(s *session) {
+	if b.deleteSession(s.pcID) {
+		b.release(s.claims)
+	}
+	b.worker.UnRegister(&s.pcID)
+	b.worker.DeleteUpList(&s.pcID)
+	_ = b.worker.RemoveConnection(&s.signalID, &s.pcID, &s.cookieID)
+	b.removeEmptyConnections(s.signalID)
+}
+
+// handleFailedPeer forget session of peer which was removed by ICE failure
+func (b *base) handleFailedPeer(signalID, role, peerConnectionID *string) {
+	if s := b.getSession(*peerConnectionID); s != nil && s.signalID == *signalID && b.deleteSession(s.pcID) {
+		b.release(s.claims)
+		b.worker.UnRegister(&s.pcID)
+		b.worker.DeleteUpList(&s.pcID)
+		b.removeEmptyConnections(s.signalID)
+	}
+}
+
+// removeEmptyConnections remove connections of signalID once its last peer is gone
+func (b *base) removeEmptyConnections(signalID string) {
+	if conns := b.worker.GetConnections(&signalID); conns != nil && conns.CountAllPeer() == 0 {
+		b.worker.RemoveConnections(&signalID)
+	}
+}
+
+// errorStatus return 403 for authorization denial, 500 otherwise
+func errorStatus(err error) int {
+	if auth.IsDenied(err) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// serveResource handle PATCH and DELETE of a session resource
+func (b *base) serveResource(w http.ResponseWriter, r *http.Request, pcID string) {
+	s := b.getSession(pcID)
+	if s == nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	claims, ok := b.verify(w, r)
+	if !ok {
+		return
+	}
+	// only owner of the session could modify it
+	if claims != nil && claims.SignalID != s.signalID {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodPatch:
+		b.handlePatch(w, r, s)
+	case http.MethodDelete:
+		b.handleDelete(w, s)
+	default:
+		w.Header().Set("Allow", "PATCH, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,160 @@
+package whip
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/spgnk/rtc/auth"
+	"github.com/spgnk/rtc/peer"
+	"github.com/spgnk/rtc/utils"
+	"github.com/spgnk/rtc/worker"
+)
+
+var _ http.Handler = (*WHEPHandler)(nil)
+
+// WHEPHandler WHEP egress endpoint.
+// POST an offer to prefix create a peer down, PATCH/DELETE prefix/pcID trickle ICE and close it.
+// Query param video/audio (repeatable) select forwarded trackID, signal_id group the peer, default is pcID
+// With a token manager, bearer token is required and signal_id is taken from its claims
+type WHEPHandler struct {
+	base
+}
+
+// NewWHEPHandler linter
+func NewWHEPHandler(w worker.Worker, prefix string, turnConfig *webrtc.Configuration) *WHEPHandler {
+	return &WHEPHandler{
+		base: newBase(w, prefix, turnConfig),
+	}
+}
+
+// ServeHTTP linter
+func (h *WHEPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if id := h.resourceID(r); id != "" {
+		h.serveResource(w, r, id)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		h.handlePost(w, r)
+	case http.MethodOptions:
+		h.handleOptions(w)
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *WHEPHandler) handlePost(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+	var s *session
+	defer func() {
+		// claims is owned by session once it is created
+		if s == nil {
+			h.release(claims)
+		}
+	}()
+
+	videoTrackIDs := r.URL.Query()[videoQuery]
+	audioTrackIDs := r.URL.Query()[audioQuery]
+	if len(videoTrackIDs) == 0 && len(audioTrackIDs) == 0 {
+		http.Error(w, "no track is requested", http.StatusBadRequest)
+		return
+	}
+	if err := h.authorizeTracks(claims, auth.ActionSubscribe, "video", videoTrackIDs); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := h.authorizeTracks(claims, auth.ActionSubscribe, "audio", audioTrackIDs); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	offer, status, err := readOffer(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	pcID := utils.GenerateID()
+	signalID := r.URL.Query().Get(signalIDQuery)
+	if claims != nil {
+		signalID = claims.SignalID
+	}
+	if signalID == "" {
+		signalID = pcID
+	}
+
+	if h.worker.GetConnections(&signalID) == nil {
+		h.worker.AddConnections(&signalID)
+	}
+
+	role := utils.PeerDown
+	configs := &peer.Configs{
+		TurnConfig:       h.turnConfig,
+		Role:             &role,
+		PeerConnectionID: &pcID,
+		AllowDownVideo:   true,
+		AllowDownAudio:   true,
+	}
+	h.applyClaims(claims, configs)
+	p, err := h.worker.AddConnection(&signalID, configs, nil, h.handleFailedPeer, nil, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s = &session{
+		signalID: signalID,
+		pcID:     pcID,
+		cookieID: *p.GetCookieID(),
+		claims:   claims,
+	}
+	h.setSession(s)
+
+	// local track must exist before offer to be matched with viewer transceiver
+	if err := h.addTracks(p, &role, videoTrackIDs, audioTrackIDs); err != nil {
+		h.closeSession(s)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sdp, err := answer(r.Context(), p, offer)
+	if err != nil {
+		h.closeSession(s)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.worker.Register(&signalID, &pcID, videoTrackIDs, audioTrackIDs, h.handleRegisterError); err != nil {
+		h.closeSession(s)
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	h.writeAnswer(w, pcID, sdp)
+}
+
+func (h *WHEPHandler) addTracks(p *peer.Peer, role *string, videoTrackIDs, audioTrackIDs []string) error {
+	for i := range videoTrackIDs {
+		trackID := videoTrackIDs[i]
+		config := peer.NewTrackConfig(&trackID, h.worker.GetVideoCodec(&trackID), role, nil).SetSource(h.worker.GetTrackSource(&trackID))
+		if err := p.AddVideoTrack(config); err != nil {
+			return err
+		}
+	}
+	for i := range audioTrackIDs {
+		trackID := audioTrackIDs[i]
+		if err := p.AddAudioTrack(peer.NewTrackConfig(&trackID, utils.ModeOpus, role, nil)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleRegisterError log write error of local track, broken viewer is removed by ICE state
+func (h *WHEPHandler) handleRegisterError(signalID, peerConnectionID, trackID *string, reason string) {
+	h.worker.GetLogger().WARN(fmt.Sprintf("whep %s forward %s err: %s", *peerConnectionID, *trackID, reason), nil)
+}
@@ -0,0 +1,143 @@
+package whip
+
+import (
+	"net/http"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/spgnk/rtc/auth"
+	"github.com/spgnk/rtc/peer"
+	"github.com/spgnk/rtc/utils"
+	"github.com/spgnk/rtc/worker"
+)
+
+const (
+	videoQuery  = "video"        // query param name of published video trackID
+	audioQuery  = "audio"        // query param name of published audio trackID
+	sourceQuery = "video_source" // query param name of published video source, camera by default
+)
+
+var _ http.Handler = (*Handler)(nil)
+
+// Handler WHIP ingest endpoint (RFC 9725).
+// POST an offer to prefix create a peer up, PATCH/DELETE prefix/pcID trickle ICE and close it.
+// Query param video/audio set published trackID, signal_id group the peer, default is pcID.
+// video_source=screen publish a screen share
+// With a token manager, bearer token is required and signal_id is taken from its claims
+type Handler struct {
+	base
+}
+
+// NewHandler linter
+func NewHandler(w worker.Worker, prefix string, turnConfig *webrtc.Configuration) *Handler {
+	return &Handler{
+		base: newBase(w, prefix, turnConfig),
+	}
+}
+
+// ServeHTTP linter
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if id := h.resourceID(r); id != "" {
+		h.serveResource(w, r, id)
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		h.handlePost(w, r)
+	case http.MethodOptions:
+		h.handleOptions(w)
+	default:
+		w.Header().Set("Allow", "POST, OPTIONS")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) handlePost(w http.ResponseWriter, r *http.Request) {
+	claims, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+	var s *session
+	defer func() {
+		// claims is owned by session once it is created
+		if s == nil {
+			h.release(claims)
+		}
+	}()
+
+	offer, status, err := readOffer(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	pcID := utils.GenerateID()
+	signalID := r.URL.Query().Get(signalIDQuery)
+	if claims != nil {
+		signalID = claims.SignalID
+	}
+	if signalID == "" {
+		signalID = pcID
+	}
+	videoTrackID := r.URL.Query().Get(videoQuery)
+	if videoTrackID == "" {
+		videoTrackID = pcID + "_" + videoQuery
+	}
+	audioTrackID := r.URL.Query().Get(audioQuery)
+	if audioTrackID == "" {
+		audioTrackID = pcID + "_" + audioQuery
+	}
+
+	if err := h.authorizeTracks(claims, auth.ActionPublish, "video", []string{videoTrackID}); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := h.authorizeTracks(claims, auth.ActionPublish, "audio", []string{audioTrackID}); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	// remote track is mapped to fwd id by up list
+	up := &worker.UpPeer{}
+	up.SetVideoList(map[string]string{videoTrackID: ""})
+	up.SetAudioList(map[string]string{audioTrackID: ""})
+	if source := r.URL.Query().Get(sourceQuery); source != "" {
+		up.SetTrackSource(videoTrackID, source)
+	}
+	h.worker.AppendUpList(&pcID, up)
+
+	if h.worker.GetConnections(&signalID) == nil {
+		h.worker.AddConnections(&signalID)
+	}
+
+	role := utils.PeerUp
+	configs := &peer.Configs{
+		TurnConfig:       h.turnConfig,
+		Role:             &role,
+		PeerConnectionID: &pcID,
+		AllowUpVideo:     true,
+		AllowUpAudio:     true,
+	}
+	h.applyClaims(claims, configs)
+	p, err := h.worker.AddConnection(&signalID, configs, nil, h.handleFailedPeer, nil, nil)
+	if err != nil {
+		h.worker.DeleteUpList(&pcID)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s = &session{
+		signalID: signalID,
+		pcID:     pcID,
+		cookieID: *p.GetCookieID(),
+		claims:   claims,
+	}
+	h.setSession(s)
+
+	sdp, err := answer(r.Context(), p, offer)
+	if err != nil {
+		h.closeSession(s)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.writeAnswer(w, pcID, sdp)
+}
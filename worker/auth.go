@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"fmt"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/spgnk/rtc/auth"
+)
+
+// SetAuthorizer set authorizer consulted on publish, subscribe and datachannel creation, nil allow everything
+func (w *PeerWorker) SetAuthorizer(authorizer auth.Authorizer) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.authorizer = authorizer
+}
+
+func (w *PeerWorker) getAuthorizer() auth.Authorizer {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.authorizer
+}
+
+// SetHandlePublishDenied set handler receive *auth.Error of remote track rejected by authorizer.
+// Receiving of rejected track is stopped
+func (w *PeerWorker) SetHandlePublishDenied(handler func(signalID, peerConnectionID *string, err error)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.deniedHandler = handler
+}
+
+func (w *PeerWorker) getDeniedHandler() func(signalID, peerConnectionID *string, err error) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.deniedHandler
+}
+
+// denyPublish stop receiving remoteTrack of peer up and report denial to app
+func (w *PeerWorker) denyPublish(signalID, peerConnectionID *string, remoteTrack *webrtc.TrackRemote, err error) {
+	w.logger.WARN(err.Error(), nil)
+	if p := w.getPeer(signalID, peerConnectionID); p != nil {
+		if stopErr := p.StopReceiving(remoteTrack); stopErr != nil {
+			w.logger.WARN(fmt.Sprintf("stop denied track of %s_%s err: %s", *signalID, *peerConnectionID, stopErr.Error()), nil)
+		}
+	}
+	if handler := w.getDeniedHandler(); handler != nil {
+		handler(signalID, peerConnectionID, err)
+	}
+}
+
+// authorizePublish return *auth.Error if signalID cannot publish trackID
+func (w *PeerWorker) authorizePublish(signalID, peerConnectionID, trackID *string, kind string) error {
+	authorizer := w.getAuthorizer()
+	if authorizer == nil {
+		return nil
+	}
+	if err := authorizer.CanPublish(*signalID, *peerConnectionID, *trackID, kind); err != nil {
+		return auth.NewError(auth.ActionPublish, *signalID, *peerConnectionID, *trackID, err)
+	}
+	return nil
+}
+
+// authorizeSubscribe return *auth.Error if signalID cannot subscribe trackID
+func (w *PeerWorker) authorizeSubscribe(signalID, peerConnectionID, trackID *string, kind string) error {
+	authorizer := w.getAuthorizer()
+	if authorizer == nil {
+		return nil
+	}
+	if err := authorizer.CanSubscribe(*signalID, *peerConnectionID, *trackID, kind); err != nil {
+		return auth.NewError(auth.ActionSubscribe, *signalID, *peerConnectionID, *trackID, err)
+	}
+	return nil
+}
+
+// authorizeDataChannel return *auth.Error if signalID cannot create datachannel peer
+func (w *PeerWorker) authorizeDataChannel(signalID, peerConnectionID *string) error {
+	authorizer := w.getAuthorizer()
+	if authorizer == nil {
+		return nil
+	}
+	if err := authorizer.CanCreateDataChannel(*signalID, *peerConnectionID); err != nil {
+		return auth.NewError(auth.ActionDataChannel, *signalID, *peerConnectionID, "", err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package worker
+
+import (
+	"sort"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/spgnk/rtc/peer"
+	"github.com/spgnk/rtc/utils"
+)
+
+// bitrateControlInterval how often publisher bitrate is recalculated
+const bitrateControlInterval = time.Second
+
+// SetMaxBitrate set max bitrate (bps) of each publisher in room, 0 is no limit
+func (w *PeerWorker) SetMaxBitrate(bitrate int) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.maxBitrate = bitrate
+}
+
+// SetBitratePercentile choose publisher bitrate from subscriber bandwidth at this percentile (0-100).
+// 0 is the minimum, no subscriber is flooded
+func (w *PeerWorker) SetBitratePercentile(percentile int) {
+	if percentile < 0 {
+		percentile = 0
+	}
+	if percentile > 100 {
+		percentile = 100
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.bitratePercentile = percentile
+}
+
+func (w *PeerWorker) getBitrateSetting() (int, int) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.maxBitrate, w.bitratePercentile
+}
+
+func (w *PeerWorker) controlBitrate() {
+	ticker := time.NewTicker(bitrateControlInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		w.updatePublisherBitrate()
+	}
+}
+
+// updatePublisherBitrate send each publisher a REMB follow bandwidth of its subscriber.
+// Simulcast publisher is only capped by max bitrate, subscriber choose layer instead
+func (w *PeerWorker) updatePublisherBitrate() {
+	maxBitrate, percentile := w.getBitrateSetting()
+	videoCount := w.countVideoSubscriptions()
+
+	targets := make(map[*peer.Peer]int)
+	for trackID, publisher := range w.getPublishers() {
+		if _, has := targets[publisher]; !has {
+			targets[publisher] = 0
+		}
+		if targets[publisher] < 0 {
+			continue
+		}
+		if w.isLayer(trackID) {
+			targets[publisher] = -1
+			continue
+		}
+		remoteTrack := w.getRemoteTrack(&trackID)
+		if remoteTrack == nil || remoteTrack.Kind() != webrtc.RTPCodecTypeVideo {
+			continue
+		}
+		if demand := w.getDemand(&trackID, percentile, videoCount); demand > 0 {
+			targets[publisher] += demand
+		}
+	}
+
+	for publisher, target := range targets {
+		if target <= 0 || (maxBitrate > 0 && target > maxBitrate) {
+			target = maxBitrate
+		}
+		publisher.SetBitrate(target / 1024)
+	}
+}
+
+// getDemand return bandwidth share of subscriber of trackID at percentile, 0 if unknown.
+// Bandwidth of a subscriber is split evenly between video tracks it receive
+func (w *PeerWorker) getDemand(trackID *string, percentile int, videoCount map[string]int) int {
+	demands := make([]int, 0)
+	for _, sub := range w.getSubscriptionsBy(trackID) {
+		subscriber := w.findPeer(&sub.pcID)
+		if subscriber == nil {
+			continue
+		}
+		bitrate := subscriberBitrate(subscriber)
+		if count := videoCount[sub.pcID]; count > 1 {
+			bitrate /= count
+		}
+		if bitrate > 0 {
+			demands = append(demands, bitrate)
+		}
+	}
+	if len(demands) == 0 {
+		return 0
+	}
+	sort.Ints(demands)
+	return demands[percentile*(len(demands)-1)/100]
+}
+
+// countVideoSubscriptions return pcID - number of video local track is forwarding, paused track is not counted
+func (w *PeerWorker) countVideoSubscriptions() map[string]int {
+	count := make(map[string]int)
+	for _, sub := range w.getSubscriptionsByKind("video") {
+		if !w.isPaused(&sub.pcID, &sub.localTrackID) {
+			count[sub.pcID]++
+		}
+	}
+	return count
+}
+
+// subscriberBitrate is the lower of REMB and estimated bandwidth of peer down
+func subscriberBitrate(p *peer.Peer) int {
+	remb := p.ReceiverBitrate()
+	estimated := p.EstimatedBitrate()
+	switch {
+	case remb <= 0:
+		return estimated
+	case estimated <= 0 || remb < estimated:
+		return remb
+	default:
+		return estimated
+	}
+}
+
+// isLayer return true if trackID is a simulcast layer
+func (w *PeerWorker) isLayer(trackID string) bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	for baseID, rids := range w.layers {
+		for rid := range rids {
+			if utils.LayerTrackID(baseID, rid) == trackID {
+				return true
+			}
+		}
+	}
+	return false
+}
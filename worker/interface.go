@@ -1,8 +1,12 @@
 package worker
 
 import (
+	"time"
+
 	"github.com/pion/webrtc/v3"
+	"github.com/spgnk/rtc/auth"
 	"github.com/spgnk/rtc/peer"
+	"github.com/spgnk/rtc/utils"
 )
 
 // Worker peer connection worker
@@ -26,6 +30,8 @@ type Worker interface {
 		handleCandidate func(signalID, peerConnectionID *string, candidate *webrtc.ICECandidate),
 		handleOnNegotiationNeeded func(signalID, peerConnectionID, cookieID *string),
 	) (*peer.Peer, error)
+	// Renegotiate send a new offer of peer to its negotiation handler
+	Renegotiate(signalID, peerConnectionID *string) error
 	GetAllConnectionID() map[string][]string
 	GetStates() map[string]string
 
@@ -63,12 +69,23 @@ type Worker interface {
 		errHandler func(signalID, peerConnectionID, trackID *string, reason string),
 	) error
 
+	// SwitchSource feed local track of pcID with data of other trackID
+	SwitchSource(pcID, localTrackID, newTrackID *string) error
+	// SelectLayer choose simulcast layer (q/h/f) for video local track of pcID
+	SelectLayer(pcID, trackID *string, rid string) error
+	GetLayers(trackID *string) []string
+	// SetSVCLayer set max vp9 spatial/temporal layer, negative value is no limit
+	SetSVCLayer(peerConnectionID, trackID *string, spatial, temporal int)
+
+	// HasPeerConnectionID report whether pcID is used by a peer of any signalID or by up list
+	HasPeerConnectionID(peerConnectionID *string) bool
 	SetUpList(lst map[string]*UpPeer)
 	DeleteUpList(peerConnectionID *string)
 	AddUpList(peerConnectionID *string, c *UpPeer)
 	AppendUpList(pcID *string, obj *UpPeer)
 
 	GetRemoteTrack(trackID *string) *webrtc.TrackRemote
+	GetVideoCodec(trackID *string) string
 	SetHandleNoConnection(handler func(signalID *string))
 
 	GetVideoReceiveTime() map[string]int64
@@ -76,6 +93,58 @@ type Worker interface {
 	GetVideoReceiveTimeby(trackID string) int64
 	GetAudioReceiveTimeby(trackID string) int64
 
+	SetVideoDropPolicy(peerConnectionID, trackID *string, policy utils.DropPolicy)
+	SetAudioDropPolicy(peerConnectionID, trackID *string, policy utils.DropPolicy)
+	GetVideoDropped(peerConnectionID, trackID *string) uint64
+	GetAudioDropped(peerConnectionID, trackID *string) uint64
+
+	// EstimatedBitrate return estimated bandwidth of peer down in bps
+	EstimatedBitrate(peerConnectionID *string) int
+	SetHandleBitrateChange(handler func(signalID, peerConnectionID *string, bitrate int))
+	// SetMaxBitrate set max bitrate (bps) of each publisher, 0 is no limit
+	SetMaxBitrate(bitrate int)
+	// SetBitratePercentile choose publisher bitrate at this percentile of subscriber bandwidth, 0 is minimum
+	SetBitratePercentile(percentile int)
+
+	// SetHandleActiveSpeaker set handler receive dominant speaker change
+	SetHandleActiveSpeaker(handler func(peerConnectionID, trackID *string))
+	GetActiveSpeaker() string
+	GetAudioLevels() map[string]float64
+
+	// PauseVideo/PauseAudio only gate forwarding, client is kept. Resume wait for keyframe
+	PauseVideo(peerConnectionID, trackID *string) error
+	ResumeVideo(peerConnectionID, trackID *string) error
+	PauseAudio(peerConnectionID, trackID *string) error
+	ResumeAudio(peerConnectionID, trackID *string) error
+	IsPaused(peerConnectionID, trackID *string) bool
+
+	// SetLastN forward video of only n most recently active speaker, 0 is all
+	SetLastN(n int)
+	GetLastN() int
+	PinVideo(peerConnectionID, trackID *string)
+	UnpinVideo(peerConnectionID, trackID *string)
+
+	// AddRoom return room roomID, create it if not exist. Participant of a room auto subscribe track of the others
+	AddRoom(roomID string) *Room
+	GetRoom(roomID string) *Room
+	RemoveRoom(roomID string)
+
+	// SetAuthorizer set authorizer consulted on publish, subscribe and datachannel creation, nil allow everything.
+	// Rejected action return *auth.Error, auth.IsDenied tell a denial from a failure
+	SetAuthorizer(authorizer auth.Authorizer)
+	// SetHandlePublishDenied set handler receive *auth.Error of remote track rejected by authorizer
+	SetHandlePublishDenied(handler func(signalID, peerConnectionID *string, err error))
+
+	// SetKeyframeInterval set min duration between 2 keyframe request send to a publisher track
+	SetKeyframeInterval(interval time.Duration)
+	// SetScreenKeyframeInterval set keyframe interval of screen track, longer than camera by default
+	SetScreenKeyframeInterval(interval time.Duration)
+	// GetTrackSource return source (camera/screen/custom) of trackID
+	GetTrackSource(trackID *string) string
+
+	// GetLogger return logger of worker, message is tagged with node id
+	GetLogger() utils.Log
+
 	GetTrackMeta(trackID string) bool
 	SetTrackMeta(trackID string, state bool)
 	SetHandleReadDeadline(f func(pcID, trackID *string, codec, kind string))
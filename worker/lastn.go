@@ -0,0 +1,178 @@
+package worker
+
+import (
+	"sort"
+
+	"github.com/spgnk/rtc/utils"
+)
+
+// SetLastN forward video of only n most recently active speaker to each subscriber, plus pinned track.
+// 0 is disable, all video is forwarded
+func (w *PeerWorker) SetLastN(n int) {
+	if n < 0 {
+		n = 0
+	}
+	w.mutex.Lock()
+	w.lastN = n
+	w.mutex.Unlock()
+	w.applyLastN()
+}
+
+// GetLastN linter
+func (w *PeerWorker) GetLastN() int {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.lastN
+}
+
+// PinVideo always forward video trackID to peerConnectionID in last-N mode
+func (w *PeerWorker) PinVideo(peerConnectionID, trackID *string) {
+	w.mutex.Lock()
+	pins := w.pinned[*peerConnectionID]
+	if pins == nil {
+		pins = make(map[string]bool)
+		w.pinned[*peerConnectionID] = pins
+	}
+	pins[*trackID] = true
+	w.mutex.Unlock()
+	w.applyLastN()
+}
+
+// UnpinVideo linter
+func (w *PeerWorker) UnpinVideo(peerConnectionID, trackID *string) {
+	w.mutex.Lock()
+	if pins := w.pinned[*peerConnectionID]; pins != nil {
+		delete(pins, *trackID)
+		if len(pins) == 0 {
+			delete(w.pinned, *peerConnectionID)
+		}
+	}
+	w.mutex.Unlock()
+	w.applyLastN()
+}
+
+func (w *PeerWorker) isPinned(peerConnectionID, trackID *string) bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.pinned[*peerConnectionID][*trackID]
+}
+
+// addRecentSpeaker move publisher pcID to the first of recent speaker
+func (w *PeerWorker) addRecentSpeaker(pcID string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	temp := []string{pcID}
+	for _, id := range w.recentSpeakers {
+		if id != pcID {
+			temp = append(temp, id)
+		}
+	}
+	w.recentSpeakers = temp
+}
+
+// activePublishers return n publisher pcID, recent speaker first then the others
+func (w *PeerWorker) activePublishers(n int) map[string]bool {
+	publishers := make(map[string]bool)
+	for pcID := range w.copyUpList() {
+		publishers[pcID] = true
+	}
+
+	w.mutex.Lock()
+	order := make([]string, 0, len(publishers))
+	recent := make([]string, 0, len(w.recentSpeakers))
+	for _, pcID := range w.recentSpeakers {
+		// forget speaker which stop publishing
+		if publishers[pcID] {
+			recent = append(recent, pcID)
+			order = append(order, pcID)
+			delete(publishers, pcID)
+		}
+	}
+	w.recentSpeakers = recent
+	w.mutex.Unlock()
+
+	others := make([]string, 0, len(publishers))
+	for pcID := range publishers {
+		others = append(others, pcID)
+	}
+	sort.Strings(others)
+	order = append(order, others...)
+
+	// publisher sharing screen come first
+	screens := w.screenPublishers()
+	sort.SliceStable(order, func(i, j int) bool {
+		return screens[order[i]] && !screens[order[j]]
+	})
+
+	active := make(map[string]bool)
+	for i := 0; i < n && i < len(order); i++ {
+		active[order[i]] = true
+	}
+	return active
+}
+
+// publisherOf return pcID of peer up publishing fwd id or base trackID, empty if unknown.
+// Publishing peer is found first, then the one declared trackID in up list
+func (w *PeerWorker) publisherOf(trackID string) string {
+	ids := []string{trackID}
+	for _, rid := range w.GetLayers(&trackID) {
+		ids = append(ids, utils.LayerTrackID(trackID, rid))
+	}
+	for _, id := range ids {
+		if publisher := w.getPublisher(&id); publisher != nil {
+			return *publisher.GetPeerConnectionID()
+		}
+	}
+
+	baseID := w.baseTrackID(trackID)
+	for pcID, up := range w.copyUpList() {
+		for _, id := range up.GetVideoArr() {
+			if id == baseID {
+				return pcID
+			}
+		}
+	}
+	return ""
+}
+
+// applyLastN pause or resume video subscription follow last-N setting
+func (w *PeerWorker) applyLastN() {
+	n := w.GetLastN()
+	var active map[string]bool
+	if n > 0 {
+		active = w.activePublishers(n)
+	}
+
+	for _, sub := range w.getSubscriptionsByKind("video") {
+		paused := n > 0 && w.lastNPause(active, &sub.pcID, &sub.localTrackID, &sub.trackID)
+		if w.setLastNPaused(&sub.pcID, &sub.localTrackID, paused) {
+			w.applyPaused(&sub)
+		}
+	}
+}
+
+// checkLastN save last-N state of new video subscription of fwd trackID before it is registered
+func (w *PeerWorker) checkLastN(pcID, localTrackID, trackID *string) {
+	n := w.GetLastN()
+	if n <= 0 {
+		return
+	}
+	w.setLastNPaused(pcID, localTrackID, w.lastNPause(w.activePublishers(n), pcID, localTrackID, trackID))
+}
+
+// lastNPause return true if video localTrackID is fed by fwd trackID of a publisher not active and is not pinned.
+// Unknown publisher is counted as active
+func (w *PeerWorker) lastNPause(active map[string]bool, pcID, localTrackID, trackID *string) bool {
+	if w.isPinned(pcID, localTrackID) {
+		return false
+	}
+	publisher := w.publisherOf(*trackID)
+	return publisher != "" && !active[publisher]
+}
+
+// setLastNPaused return true if state was changed
+func (w *PeerWorker) setLastNPaused(pcID, localTrackID *string, paused bool) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return setPauseState(w.lastNPaused, pcID, localTrackID, paused)
+}
@@ -92,6 +92,20 @@ func (w *PeerWorker) getPeer(signalID, peerConnectionID *string) *peer.Peer {
 	return nil
 }
 
+// findPeer find peer by peerConnectionID in all connections
+func (w *PeerWorker) findPeer(peerConnectionID *string) *peer.Peer {
+	var result *peer.Peer
+	if peers := w.getPeers(); peers != nil {
+		peers.Iter(func(key, value interface{}) bool {
+			if connections, ok := value.(peer.Connections); ok {
+				result = connections.GetConnection(peerConnectionID)
+			}
+			return result == nil
+		})
+	}
+	return result
+}
+
 func (w *PeerWorker) deleteUpList(peerConnectionID *string) {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
@@ -110,6 +124,17 @@ func (w *PeerWorker) getUpList() map[string]*UpPeer {
 	return w.upList
 }
 
+// copyUpList return copy of pcID - up peer, safe to iterate while up list change
+func (w *PeerWorker) copyUpList() map[string]*UpPeer {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	temp := make(map[string]*UpPeer, len(w.upList))
+	for pcID, up := range w.upList {
+		temp[pcID] = up
+	}
+	return temp
+}
+
 func (w *PeerWorker) setUpList(lst map[string]*UpPeer) {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
@@ -157,3 +182,65 @@ func (w *PeerWorker) deleteRemoteTrack(trackID *string) {
 	defer w.mutex.Unlock()
 	delete(w.tracks, *trackID)
 }
+
+func (w *PeerWorker) setPublisher(trackID *string, p *peer.Peer) {
+	if p == nil {
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.publishers[*trackID] = p
+}
+
+func (w *PeerWorker) getPublisher(trackID *string) *peer.Peer {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.publishers[*trackID]
+}
+
+// getPublisherCodecs return codecs negotiated by publisher of trackID, nil if trackID is not published
+func (w *PeerWorker) getPublisherCodecs(trackID *string) []webrtc.RTPCodecParameters {
+	publisher := w.getPublisher(trackID)
+	remoteTrack := w.getRemoteTrack(trackID)
+	if publisher == nil || remoteTrack == nil {
+		return nil
+	}
+	return publisher.GetRemoteCodecs(remoteTrack)
+}
+
+// getPublishers return copy of trackID - publisher
+func (w *PeerWorker) getPublishers() map[string]*peer.Peer {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	temp := make(map[string]*peer.Peer, len(w.publishers))
+	for trackID, p := range w.publishers {
+		temp[trackID] = p
+	}
+	return temp
+}
+
+// deletePublisher only delete if trackID still belong to this peer
+func (w *PeerWorker) deletePublisher(trackID *string, p *peer.Peer) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.publishers[*trackID] == p {
+		delete(w.publishers, *trackID)
+		delete(w.keyframeTime, *trackID)
+	}
+}
+
+// allowKeyframe return true and save time if keyframe interval of trackID was passed
+func (w *PeerWorker) allowKeyframe(trackID *string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	now := time.Now()
+	interval := w.keyframeInterval
+	if w.sources[*trackID] == utils.SourceScreen {
+		interval = w.screenInterval
+	}
+	if now.Sub(w.keyframeTime[*trackID]) < interval {
+		return false
+	}
+	w.keyframeTime[*trackID] = now
+	return true
+}
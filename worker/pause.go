@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"fmt"
+
+	"github.com/spgnk/rtc/errs"
+)
+
+// PauseVideo stop forwarding video trackID to peerConnectionID, client and its rewriter are kept
+func (w *PeerWorker) PauseVideo(peerConnectionID, trackID *string) error {
+	return w.setManualPaused(peerConnectionID, trackID, "video", true)
+}
+
+// ResumeVideo continue forwarding video trackID to peerConnectionID from the next keyframe
+func (w *PeerWorker) ResumeVideo(peerConnectionID, trackID *string) error {
+	return w.setManualPaused(peerConnectionID, trackID, "video", false)
+}
+
+// PauseAudio stop forwarding audio trackID to peerConnectionID, client and its rewriter are kept
+func (w *PeerWorker) PauseAudio(peerConnectionID, trackID *string) error {
+	return w.setManualPaused(peerConnectionID, trackID, "audio", true)
+}
+
+// ResumeAudio continue forwarding audio trackID to peerConnectionID
+func (w *PeerWorker) ResumeAudio(peerConnectionID, trackID *string) error {
+	return w.setManualPaused(peerConnectionID, trackID, "audio", false)
+}
+
+// IsPaused return true if forwarding trackID to peerConnectionID is paused by user or last-N
+func (w *PeerWorker) IsPaused(peerConnectionID, trackID *string) bool {
+	return w.isPaused(peerConnectionID, trackID)
+}
+
+func (w *PeerWorker) setManualPaused(peerConnectionID, trackID *string, kind string, paused bool) error {
+	sub := w.getSubscription(peerConnectionID, trackID)
+	if sub == nil || sub.kind != kind {
+		return fmt.Errorf("%s_%s %s", *peerConnectionID, *trackID, errs.ErrW002.Error())
+	}
+
+	w.mutex.Lock()
+	changed := setPauseState(w.manualPaused, peerConnectionID, trackID, paused)
+	w.mutex.Unlock()
+
+	if changed {
+		w.applyPaused(sub)
+	}
+	return nil
+}
+
+// setPauseState save state of localTrackID in states, return true if state was changed.
+// Caller must hold the worker mutex
+func setPauseState(states map[string]map[string]bool, pcID, localTrackID *string, paused bool) bool {
+	tracks := states[*pcID]
+	if tracks[*localTrackID] == paused {
+		return false
+	}
+	if paused {
+		if tracks == nil {
+			tracks = make(map[string]bool)
+			states[*pcID] = tracks
+		}
+		tracks[*localTrackID] = true
+		return true
+	}
+	delete(tracks, *localTrackID)
+	if len(tracks) == 0 {
+		delete(states, *pcID)
+	}
+	return true
+}
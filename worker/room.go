@@ -0,0 +1,392 @@
+package worker
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pion/webrtc/v3"
+	"github.com/spgnk/rtc/errs"
+	"github.com/spgnk/rtc/peer"
+	"github.com/spgnk/rtc/utils"
+)
+
+// Room event type
+const (
+	RoomEventJoin             = "join"
+	RoomEventLeave            = "leave"
+	RoomEventTrackPublished   = "track-published"
+	RoomEventTrackUnpublished = "track-unpublished"
+)
+
+// RoomEvent linter
+type RoomEvent struct {
+	Type     string
+	RoomID   string
+	SignalID string // participant of the event
+	TrackID  string // track event only
+	Kind     string // video or audio, track event only
+}
+
+// roomTrack is a track published in a room
+type roomTrack struct {
+	kind  string
+	codec string // codec mode of local track created for subscriber
+}
+
+type participant struct {
+	subscriber string                // pcID of peer down receive track of the others, empty if not subscribed
+	tracks     map[string]*roomTrack // save trackID - track published by participant
+}
+
+// Room group participant by signalID.
+// Track published by a peer up of a participant is forwarded to the subscriber peer of every other participant.
+// Subscriber peer is renegotiated on track change, each offer is sent to offer handler and its answer is set by Connections.AddSDP
+type Room struct {
+	id           string
+	worker       *PeerWorker
+	participants map[string]*participant // save signalID - participant
+	eventHandler func(event *RoomEvent)
+	offerHandler func(signalID, peerConnectionID *string, offer *webrtc.SessionDescription)
+	mutex        sync.Mutex
+}
+
+func newRoom(id string, w *PeerWorker) *Room {
+	return &Room{
+		id:           id,
+		worker:       w,
+		participants: make(map[string]*participant),
+	}
+}
+
+// GetID linter
+func (r *Room) GetID() string {
+	return r.id
+}
+
+// SetHandleEvent set handler receive join/leave/track-published/track-unpublished event
+func (r *Room) SetHandleEvent(handler func(event *RoomEvent)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.eventHandler = handler
+}
+
+// SetHandleOffer set handler receive offer of subscriber peer
+func (r *Room) SetHandleOffer(handler func(signalID, peerConnectionID *string, offer *webrtc.SessionDescription)) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.offerHandler = handler
+}
+
+// Join add participant signalID. Track is published once its peer up of signalID receive media
+func (r *Room) Join(signalID string) error {
+	r.mutex.Lock()
+	if _, has := r.participants[signalID]; has {
+		r.mutex.Unlock()
+		return fmt.Errorf("%s %s", signalID, errs.ErrW005.Error())
+	}
+	r.participants[signalID] = &participant{
+		tracks: make(map[string]*roomTrack),
+	}
+	handler := r.eventHandler
+	r.mutex.Unlock()
+
+	r.emit(handler, []*RoomEvent{r.newEvent(RoomEventJoin, signalID, "", "")})
+	return nil
+}
+
+// Leave remove participant signalID, its track is removed from the others and its subscriber stop receiving.
+// Peer of signalID is not closed
+func (r *Room) Leave(signalID string) error {
+	r.mutex.Lock()
+	p, has := r.participants[signalID]
+	if !has {
+		r.mutex.Unlock()
+		return fmt.Errorf("%s %s", signalID, errs.ErrW004.Error())
+	}
+
+	events := make([]*RoomEvent, 0, len(p.tracks)+1)
+	for trackID, track := range p.tracks {
+		r.removeTrack(signalID, trackID, track)
+		events = append(events, r.newEvent(RoomEventTrackUnpublished, signalID, trackID, track.kind))
+	}
+	if p.subscriber != "" {
+		r.worker.UnRegister(&p.subscriber)
+	}
+	delete(r.participants, signalID)
+	events = append(events, r.newEvent(RoomEventLeave, signalID, "", ""))
+
+	handler := r.eventHandler
+	r.mutex.Unlock()
+
+	r.emit(handler, events)
+	return nil
+}
+
+// Subscribe use peer down peerConnectionID of signalID to receive every track published by the others.
+// Existing track is added at once, negotiation of the peer is taken by the room
+func (r *Room) Subscribe(signalID, peerConnectionID string) error {
+	r.mutex.Lock()
+	p, has := r.participants[signalID]
+	if !has {
+		r.mutex.Unlock()
+		return fmt.Errorf("%s %s", signalID, errs.ErrW004.Error())
+	}
+	conn := r.worker.getPeer(&signalID, &peerConnectionID)
+	if conn == nil {
+		r.mutex.Unlock()
+		return fmt.Errorf("[%s-%s] %s", signalID, peerConnectionID, errs.ErrP002)
+	}
+	if p.subscriber != "" && p.subscriber != peerConnectionID {
+		r.worker.UnRegister(&p.subscriber)
+	}
+	p.subscriber = peerConnectionID
+	conn.SetNegotiationHandler(func(offer *webrtc.SessionDescription) {
+		r.sendOffer(signalID, peerConnectionID, offer)
+	})
+
+	for otherID, other := range r.participants {
+		if otherID == signalID {
+			continue
+		}
+		for trackID, track := range other.tracks {
+			if err := r.addTrack(signalID, peerConnectionID, conn, trackID, track); err != nil {
+				r.worker.logger.ERROR(fmt.Sprintf("room %s add %s to %s err: %s", r.id, trackID, peerConnectionID, err.Error()), nil)
+			}
+		}
+	}
+	r.mutex.Unlock()
+	return nil
+}
+
+// GetParticipants return signalID of all participant
+func (r *Room) GetParticipants() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	temp := make([]string, 0, len(r.participants))
+	for signalID := range r.participants {
+		temp = append(temp, signalID)
+	}
+	return temp
+}
+
+// GetTracks return trackID - kind published by signalID
+func (r *Room) GetTracks(signalID string) map[string]string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	temp := make(map[string]string)
+	if p, has := r.participants[signalID]; has {
+		for trackID, track := range p.tracks {
+			temp[trackID] = track.kind
+		}
+	}
+	return temp
+}
+
+// publish add track of signalID to subscriber of the others
+func (r *Room) publish(signalID, trackID, kind, codec string) {
+	r.mutex.Lock()
+	p, has := r.participants[signalID]
+	if !has {
+		r.mutex.Unlock()
+		return
+	}
+	if _, has := p.tracks[trackID]; has {
+		r.mutex.Unlock()
+		return
+	}
+	track := &roomTrack{
+		kind:  kind,
+		codec: codec,
+	}
+	p.tracks[trackID] = track
+
+	for otherID, other := range r.participants {
+		if otherID == signalID || other.subscriber == "" {
+			continue
+		}
+		conn := r.worker.getPeer(&otherID, &other.subscriber)
+		if conn == nil {
+			continue
+		}
+		if err := r.addTrack(otherID, other.subscriber, conn, trackID, track); err != nil {
+			r.worker.logger.ERROR(fmt.Sprintf("room %s add %s to %s err: %s", r.id, trackID, other.subscriber, err.Error()), nil)
+		}
+	}
+	handler := r.eventHandler
+	r.mutex.Unlock()
+
+	r.emit(handler, []*RoomEvent{r.newEvent(RoomEventTrackPublished, signalID, trackID, kind)})
+}
+
+// unpublish remove track of signalID from subscriber of the others
+func (r *Room) unpublish(signalID, trackID string) {
+	r.mutex.Lock()
+	p, has := r.participants[signalID]
+	if !has {
+		r.mutex.Unlock()
+		return
+	}
+	track, has := p.tracks[trackID]
+	if !has {
+		r.mutex.Unlock()
+		return
+	}
+	delete(p.tracks, trackID)
+	r.removeTrack(signalID, trackID, track)
+	handler := r.eventHandler
+	r.mutex.Unlock()
+
+	r.emit(handler, []*RoomEvent{r.newEvent(RoomEventTrackUnpublished, signalID, trackID, track.kind)})
+}
+
+// addTrack create local track of trackID in subscriber peer and forward trackID to it
+func (r *Room) addTrack(signalID, pcID string, conn *peer.Peer, trackID string, track *roomTrack) error {
+	// denied track must not leave a local track in subscriber peer, check before adding it
+	if err := r.worker.authorizeSubscribe(&signalID, &pcID, &trackID, track.kind); err != nil {
+		return err
+	}
+
+	role := utils.PeerDown
+	config := peer.NewTrackConfig(&trackID, track.codec, &role, nil).SetSource(r.worker.GetTrackSource(&trackID))
+	if track.kind == "video" {
+		if err := conn.AddVideoTrack(config); err != nil {
+			return err
+		}
+		if err := r.worker.RegisterVideo(&signalID, &trackID, &pcID, r.handleRegisterError); err != nil {
+			// local track without forwarding is removed, renegotiation drop it
+			r.removeLocalTrack(conn, pcID, trackID, track.kind)
+			return err
+		}
+		return nil
+	}
+	if err := conn.AddAudioTrack(config); err != nil {
+		return err
+	}
+	if err := r.worker.RegisterAudio(&signalID, &trackID, &pcID, r.handleRegisterError); err != nil {
+		r.removeLocalTrack(conn, pcID, trackID, track.kind)
+		return err
+	}
+	return nil
+}
+
+func (r *Room) removeLocalTrack(conn *peer.Peer, pcID, trackID, kind string) {
+	var err error
+	if kind == "video" {
+		err = conn.RemoveVideoTrack(&trackID)
+	} else {
+		err = conn.RemoveAudioTrack(&trackID)
+	}
+	if err != nil {
+		r.worker.logger.WARN(fmt.Sprintf("room %s remove %s from %s err: %s", r.id, trackID, pcID, err.Error()), nil)
+	}
+}
+
+// removeTrack stop forwarding trackID of publisherID to the others
+func (r *Room) removeTrack(publisherID, trackID string, track *roomTrack) {
+	for otherID, other := range r.participants {
+		if otherID == publisherID || other.subscriber == "" {
+			continue
+		}
+		conn := r.worker.getPeer(&otherID, &other.subscriber)
+		if conn == nil {
+			continue
+		}
+
+		if track.kind == "video" {
+			r.worker.UnRegisterVideo(&other.subscriber, &trackID)
+		} else {
+			r.worker.UnRegisterAudio(&other.subscriber, &trackID)
+		}
+		r.removeLocalTrack(conn, other.subscriber, trackID, track.kind)
+	}
+}
+
+// sendOffer send renegotiation offer of subscriber peer to offer handler
+func (r *Room) sendOffer(signalID, pcID string, offer *webrtc.SessionDescription) {
+	r.mutex.Lock()
+	handler := r.offerHandler
+	r.mutex.Unlock()
+	if handler != nil {
+		handler(&signalID, &pcID, offer)
+	}
+}
+
+func (r *Room) emit(handler func(event *RoomEvent), events []*RoomEvent) {
+	if handler == nil {
+		return
+	}
+	for _, event := range events {
+		handler(event)
+	}
+}
+
+func (r *Room) newEvent(eventType, signalID, trackID, kind string) *RoomEvent {
+	return &RoomEvent{
+		Type:     eventType,
+		RoomID:   r.id,
+		SignalID: signalID,
+		TrackID:  trackID,
+		Kind:     kind,
+	}
+}
+
+func (r *Room) handleRegisterError(signalID, peerConnectionID, trackID *string, reason string) {
+	r.worker.logger.WARN(fmt.Sprintf("room %s forward %s to %s err: %s", r.id, *trackID, *peerConnectionID, reason), nil)
+}
+
+// AddRoom return room roomID, create it if not exist
+func (w *PeerWorker) AddRoom(roomID string) *Room {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if room, has := w.rooms[roomID]; has {
+		return room
+	}
+	room := newRoom(roomID, w)
+	w.rooms[roomID] = room
+	return room
+}
+
+// GetRoom return nil if room not exist
+func (w *PeerWorker) GetRoom(roomID string) *Room {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.rooms[roomID]
+}
+
+// RemoveRoom make every participant leave then delete room
+func (w *PeerWorker) RemoveRoom(roomID string) {
+	w.mutex.Lock()
+	room, has := w.rooms[roomID]
+	delete(w.rooms, roomID)
+	w.mutex.Unlock()
+	if !has {
+		return
+	}
+	for _, signalID := range room.GetParticipants() {
+		_ = room.Leave(signalID)
+	}
+}
+
+func (w *PeerWorker) getRooms() []*Room {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	temp := make([]*Room, 0, len(w.rooms))
+	for _, room := range w.rooms {
+		temp = append(temp, room)
+	}
+	return temp
+}
+
+// handleTrackPublished notify room of signalID that trackID has media
+func (w *PeerWorker) handleTrackPublished(signalID, trackID, kind, codec *string) {
+	for _, room := range w.getRooms() {
+		room.publish(*signalID, *trackID, *kind, utils.GetModeType(*codec))
+	}
+}
+
+// handleTrackUnpublished notify room of signalID that trackID stop
+func (w *PeerWorker) handleTrackUnpublished(signalID, trackID *string) {
+	for _, room := range w.getRooms() {
+		room.unpublish(*signalID, *trackID)
+	}
+}
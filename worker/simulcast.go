@@ -0,0 +1,117 @@
+package worker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spgnk/rtc/errs"
+	"github.com/spgnk/rtc/utils"
+)
+
+// layerPreference is order to choose default layer for new subscriber
+var layerPreference = []string{utils.RIDHalf, utils.RIDFull, utils.RIDQuarter}
+
+// screenLayerPreference screen favor resolution over framerate
+var screenLayerPreference = []string{utils.RIDFull, utils.RIDHalf, utils.RIDQuarter}
+
+// SelectLayer switch video local track trackID of pcID to simulcast layer rid (q/h/f).
+// Switching happen on keyframe of new layer. Empty rid is only valid for non simulcast trackID
+func (w *PeerWorker) SelectLayer(pcID, trackID *string, rid string) error {
+	if rid == "" && len(w.GetLayers(trackID)) > 0 {
+		return fmt.Errorf("%s %s", *trackID, errs.ErrW006.Error())
+	}
+	if rid != "" && !w.hasLayer(trackID, rid) {
+		return fmt.Errorf("%s_%s %s", *trackID, rid, errs.ErrW003.Error())
+	}
+	layerID := utils.LayerTrackID(*trackID, rid)
+	return w.SwitchSource(pcID, trackID, &layerID)
+}
+
+// GetLayers return all publishing simulcast layer of trackID
+func (w *PeerWorker) GetLayers(trackID *string) []string {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	temp := make([]string, 0)
+	for rid := range w.layers[*trackID] {
+		temp = append(temp, rid)
+	}
+	sort.Strings(temp)
+	return temp
+}
+
+// addLayer return true if this is the first layer of trackID
+func (w *PeerWorker) addLayer(trackID *string, rid string) bool {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	rids := w.layers[*trackID]
+	if rids == nil {
+		rids = make(map[string]bool)
+		w.layers[*trackID] = rids
+	}
+	rids[rid] = true
+	return len(rids) == 1
+}
+
+func (w *PeerWorker) deleteLayer(trackID *string, rid string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if rids := w.layers[*trackID]; rids != nil {
+		delete(rids, rid)
+		if len(rids) == 0 {
+			delete(w.layers, *trackID)
+		}
+	}
+}
+
+func (w *PeerWorker) hasLayer(trackID *string, rid string) bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.layers[*trackID][rid]
+}
+
+// baseTrackID return trackID of simulcast layer fwdID, fwdID itself if it is not a layer
+func (w *PeerWorker) baseTrackID(fwdID string) string {
+	for _, rid := range []string{utils.RIDQuarter, utils.RIDHalf, utils.RIDFull} {
+		if base := strings.TrimSuffix(fwdID, "_"+rid); base != fwdID && w.hasLayer(&base, rid) {
+			return base
+		}
+	}
+	return fwdID
+}
+
+// defaultSource return fwd id for new subscriber of trackID.
+// Simulcast trackID return fwd id of the preference layer
+func (w *PeerWorker) defaultSource(trackID *string) *string {
+	layers := w.GetLayers(trackID)
+	if len(layers) == 0 {
+		return trackID
+	}
+
+	preferences := layerPreference
+	if w.GetTrackSource(trackID) == utils.SourceScreen {
+		preferences = screenLayerPreference
+	}
+
+	rid := layers[0]
+	for _, preference := range preferences {
+		if w.hasLayer(trackID, preference) {
+			rid = preference
+			break
+		}
+	}
+	layerID := utils.LayerTrackID(*trackID, rid)
+	return &layerID
+}
+
+// moveToLayer switch subscriber which registered before simulcast publisher to layerID
+func (w *PeerWorker) moveToLayer(trackID, layerID *string) {
+	for _, sub := range w.getSubscriptionsBy(trackID) {
+		if sub.kind != "video" {
+			continue
+		}
+		if err := w.registerVideo(&sub.signalID, layerID, &sub.localTrackID, &sub.pcID, sub.errHandler); err != nil {
+			w.logger.ERROR(fmt.Sprintf("%s move to layer %s err: %s", sub.pcID, *layerID, err.Error()), nil)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"time"
+
+	"github.com/spgnk/rtc/utils"
+)
+
+// defaultScreenKeyframeInterval screen content change rarely, keyframe of screen is big
+const defaultScreenKeyframeInterval = 2 * time.Second
+
+// GetTrackSource return source (camera/screen/custom) of trackID, published source first then declared in up list.
+// Published video without declared source is camera, empty if trackID is unknown
+func (w *PeerWorker) GetTrackSource(trackID *string) string {
+	w.mutex.RLock()
+	source, has := w.sources[*trackID]
+	w.mutex.RUnlock()
+	if has {
+		return source
+	}
+	for _, up := range w.copyUpList() {
+		if source := up.GetTrackSource(*trackID); source != "" {
+			return source
+		}
+	}
+	return ""
+}
+
+// SetScreenKeyframeInterval set min duration between 2 keyframe request send to a screen track
+func (w *PeerWorker) SetScreenKeyframeInterval(interval time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.screenInterval = interval
+}
+
+// declaredSource return source of trackID declared in up list of pcID, video default to camera
+func (w *PeerWorker) declaredSource(pcID, trackID *string, kind string) string {
+	if up := w.getUpPeer(pcID); up != nil {
+		if source := up.GetTrackSource(*trackID); source != "" {
+			return source
+		}
+	}
+	if kind == "video" {
+		return utils.SourceCamera
+	}
+	return ""
+}
+
+func (w *PeerWorker) setTrackSource(trackID *string, source string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.sources[*trackID] = source
+}
+
+func (w *PeerWorker) deleteTrackSource(trackID *string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	delete(w.sources, *trackID)
+}
+
+// screenPublishers return pcID of peer up publishing a screen track
+func (w *PeerWorker) screenPublishers() map[string]bool {
+	w.mutex.RLock()
+	screens := make([]string, 0)
+	for trackID, source := range w.sources {
+		if source == utils.SourceScreen {
+			screens = append(screens, trackID)
+		}
+	}
+	w.mutex.RUnlock()
+
+	temp := make(map[string]bool)
+	for _, trackID := range screens {
+		if pcID := w.publisherOf(trackID); pcID != "" {
+			temp[pcID] = true
+		}
+	}
+	return temp
+}
@@ -0,0 +1,160 @@
+package worker
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	speakerInterval   = 300 * time.Millisecond // how often dominant speaker is checked
+	speakerTimeout    = time.Second            // level of track without packet in this duration is silent
+	speakerSmoothing  = 0.1                    // weight of new level in smoothed level
+	speakerThreshold  = 50                     // min smoothed level to become dominant speaker
+	speakerHysteresis = 10                     // new speaker must be louder than current speaker by this
+)
+
+// audioLevel smoothed level of an audio track
+type audioLevel struct {
+	level    float64   // 0 is silent, 127 is loudest
+	lastTime time.Time // last time receive level
+}
+
+// speakerDetector keep smoothed audio level of each audio trackID and choose the dominant speaker
+type speakerDetector struct {
+	levels   map[string]*audioLevel
+	dominant string
+	mutex    sync.Mutex
+}
+
+func newSpeakerDetector() *speakerDetector {
+	return &speakerDetector{
+		levels: make(map[string]*audioLevel),
+	}
+}
+
+// update add audio level (0 is loudest, 127 is silent) of a packet
+func (s *speakerDetector) update(trackID string, level uint8) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	l := s.levels[trackID]
+	if l == nil {
+		l = &audioLevel{}
+		s.levels[trackID] = l
+	}
+	l.level += (float64(127-level) - l.level) * speakerSmoothing
+	l.lastTime = time.Now()
+}
+
+func (s *speakerDetector) remove(trackID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.levels, trackID)
+}
+
+func (s *speakerDetector) getLevels() map[string]float64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	temp := make(map[string]float64, len(s.levels))
+	for trackID, l := range s.levels {
+		temp[trackID] = l.level
+	}
+	return temp
+}
+
+func (s *speakerDetector) getDominant() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dominant
+}
+
+// detect return dominant speaker and true if it was changed
+func (s *speakerDetector) detect() (string, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	var loudest string
+	var loudestLevel float64
+	for trackID, l := range s.levels {
+		if now.Sub(l.lastTime) > speakerTimeout {
+			l.level = 0
+		}
+		if l.level > loudestLevel {
+			loudest = trackID
+			loudestLevel = l.level
+		}
+	}
+
+	current, has := s.levels[s.dominant]
+	switch {
+	case !has && s.dominant != "":
+		// dominant speaker stop publishing
+		s.dominant = ""
+		if loudestLevel >= speakerThreshold {
+			s.dominant = loudest
+		}
+		return s.dominant, true
+	case loudestLevel < speakerThreshold || loudest == s.dominant:
+		return s.dominant, false
+	case s.dominant == "" || loudestLevel > current.level+speakerHysteresis:
+		s.dominant = loudest
+		return s.dominant, true
+	}
+	return s.dominant, false
+}
+
+// SetHandleActiveSpeaker set handler receive dominant speaker change.
+// trackID is audio track id of new speaker, empty if nobody is speaking
+func (w *PeerWorker) SetHandleActiveSpeaker(handler func(peerConnectionID, trackID *string)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.speakerHandler = handler
+}
+
+// GetActiveSpeaker return audio track id of dominant speaker
+func (w *PeerWorker) GetActiveSpeaker() string {
+	return w.speakers.getDominant()
+}
+
+// GetAudioLevels return smoothed level (0 is silent, 127 is loudest) of all audio track
+func (w *PeerWorker) GetAudioLevels() map[string]float64 {
+	return w.speakers.getLevels()
+}
+
+func (w *PeerWorker) handleAudioLevel(trackID string, level uint8, voice bool) {
+	w.speakers.update(trackID, level)
+}
+
+func (w *PeerWorker) getSpeakerHandler() func(peerConnectionID, trackID *string) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.speakerHandler
+}
+
+func (w *PeerWorker) detectSpeaker() {
+	ticker := time.NewTicker(speakerInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		trackID, changed := w.speakers.detect()
+		if !changed {
+			continue
+		}
+
+		var pcID string
+		if publisher := w.getPublisher(&trackID); publisher != nil {
+			pcID = *publisher.GetPeerConnectionID()
+		}
+
+		// last-N follow the new speaker
+		if pcID != "" {
+			w.addRecentSpeaker(pcID)
+			w.applyLastN()
+		}
+
+		handler := w.getSpeakerHandler()
+		if handler == nil {
+			continue
+		}
+		handler(&pcID, &trackID)
+	}
+}
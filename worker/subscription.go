@@ -0,0 +1,194 @@
+package worker
+
+import (
+	"github.com/spgnk/rtc/peer"
+	"github.com/spgnk/rtc/utils"
+)
+
+// subscription save which forwarder is feeding a local track of subscriber
+type subscription struct {
+	signalID     string
+	pcID         string
+	localTrackID string // local track id on subscriber peer
+	trackID      string // forwarder id
+	kind         string // audio or video
+	prevTrackID  string // old fwd id, still feeding until new fwd start
+	errHandler   func(signalID, peerConnectionID, trackID *string, reason string)
+}
+
+func (w *PeerWorker) setSubscription(s *subscription) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	subs := w.subscriptions[s.pcID]
+	if subs == nil {
+		subs = make(map[string]*subscription)
+		w.subscriptions[s.pcID] = subs
+	}
+	subs[s.localTrackID] = s
+}
+
+func (w *PeerWorker) getSubscription(pcID, localTrackID *string) *subscription {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if subs := w.subscriptions[*pcID]; subs != nil {
+		return subs[*localTrackID]
+	}
+	return nil
+}
+
+func (w *PeerWorker) deleteSubscription(pcID, localTrackID *string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if subs := w.subscriptions[*pcID]; subs != nil {
+		delete(subs, *localTrackID)
+		if len(subs) == 0 {
+			delete(w.subscriptions, *pcID)
+		}
+	}
+}
+
+func (w *PeerWorker) deleteSubscriptions(pcID *string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	delete(w.subscriptions, *pcID)
+}
+
+// sourceOf return fwd id is feeding local track, default is the local track id
+func (w *PeerWorker) sourceOf(pcID, localTrackID *string) *string {
+	if sub := w.getSubscription(pcID, localTrackID); sub != nil {
+		return &sub.trackID
+	}
+	return localTrackID
+}
+
+// subscribe register sub into fwdm. If local track was fed by other fwd,
+// the old fwd keep feeding until the new one start (on keyframe for video)
+func (w *PeerWorker) subscribe(fwdm utils.Fwdm, p *peer.Peer, sub *subscription, handler func(trackID string, wrapper *utils.Wrapper) error) {
+	if old := w.getSubscription(&sub.pcID, &sub.localTrackID); old != nil {
+		sub.prevTrackID = old.prevTrackID
+		if old.trackID != sub.trackID {
+			// cancel the older pending switch
+			if old.prevTrackID != "" && old.prevTrackID != sub.trackID {
+				fwdm.Unregister(&old.prevTrackID, &sub.pcID)
+			}
+			sub.prevTrackID = old.trackID
+		}
+	}
+
+	var onStart func()
+	if prevTrackID := sub.prevTrackID; prevTrackID != "" {
+		onStart = func() {
+			p.SwitchSource(&sub.localTrackID)
+			fwdm.Unregister(&prevTrackID, &sub.pcID)
+			w.clearPrevTrackID(&sub.pcID, &sub.localTrackID, prevTrackID)
+		}
+	}
+
+	w.setSubscription(sub)
+	// new fwd keep pause state of local track, fwd is created here instead of by register to hold the state
+	if w.isPaused(&sub.pcID, &sub.localTrackID) {
+		fwdm.AddNewForwarder(sub.trackID)
+		fwdm.SetClientPaused(sub.trackID, sub.pcID, true)
+	}
+	fwdm.RegisterWithStart(sub.trackID, sub.pcID, handler, onStart)
+}
+
+func (w *PeerWorker) clearPrevTrackID(pcID, localTrackID *string, prevTrackID string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if subs := w.subscriptions[*pcID]; subs != nil {
+		if sub := subs[*localTrackID]; sub != nil && sub.prevTrackID == prevTrackID {
+			sub.prevTrackID = ""
+		}
+	}
+}
+
+// getSubscriptionsBy return copy of all subscription fed by fwd trackID
+func (w *PeerWorker) getSubscriptionsBy(trackID *string) []subscription {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	temp := make([]subscription, 0)
+	for _, subs := range w.subscriptions {
+		for _, sub := range subs {
+			if sub.trackID == *trackID {
+				temp = append(temp, *sub)
+			}
+		}
+	}
+	return temp
+}
+
+// getSubscriptionsByKind return copy of all audio or video subscription
+func (w *PeerWorker) getSubscriptionsByKind(kind string) []subscription {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	temp := make([]subscription, 0)
+	for _, subs := range w.subscriptions {
+		for _, sub := range subs {
+			if sub.kind == kind {
+				temp = append(temp, *sub)
+			}
+		}
+	}
+	return temp
+}
+
+// isPaused return true if forwarding to local track is paused
+func (w *PeerWorker) isPaused(pcID, localTrackID *string) bool {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.lastNPaused[*pcID][*localTrackID] || w.manualPaused[*pcID][*localTrackID]
+}
+
+// deletePaused remove pause state of local track, nil localTrackID remove all of pcID
+func (w *PeerWorker) deletePaused(pcID, localTrackID *string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if localTrackID == nil {
+		delete(w.lastNPaused, *pcID)
+		delete(w.manualPaused, *pcID)
+		return
+	}
+	setPauseState(w.lastNPaused, pcID, localTrackID, false)
+	setPauseState(w.manualPaused, pcID, localTrackID, false)
+}
+
+// applyPaused set pause state of local track to its fwd. Resume wait for keyframe
+func (w *PeerWorker) applyPaused(sub *subscription) {
+	fwdm := w.audioFwdm
+	if sub.kind == "video" {
+		fwdm = w.videoFwdm
+	}
+
+	paused := w.isPaused(&sub.pcID, &sub.localTrackID)
+	if !paused {
+		if p := w.getPeer(&sub.signalID, &sub.pcID); p != nil {
+			p.ResyncSource(&sub.localTrackID)
+		}
+	}
+
+	// register of sub.trackID may not have created its fwd yet
+	fwdm.AddNewForwarder(sub.trackID)
+	for _, trackID := range []string{sub.trackID, sub.prevTrackID} {
+		if trackID != "" {
+			fwdm.SetClientPaused(trackID, sub.pcID, paused)
+		}
+	}
+}
+
+// getCachedPacket find source packet of local track in fwd cache, also look in old fwd if switching
+func (w *PeerWorker) getCachedPacket(pcID, localTrackID *string, ssrc uint32, seq uint16) ([]byte, bool) {
+	trackIDs := []string{*localTrackID}
+	if sub := w.getSubscription(pcID, localTrackID); sub != nil {
+		trackIDs = []string{sub.trackID, sub.prevTrackID}
+	}
+	for _, trackID := range trackIDs {
+		if trackID == "" {
+			continue
+		}
+		if data, ok := w.videoFwdm.GetPacket(trackID, ssrc, seq); ok {
+			return data, true
+		}
+	}
+	return nil, false
+}
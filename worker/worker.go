@@ -3,9 +3,11 @@ package worker
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/pion/rtp"
 	"github.com/pion/webrtc/v3"
+	"github.com/spgnk/rtc/auth"
 	"github.com/spgnk/rtc/errs"
 	"github.com/spgnk/rtc/peer"
 	"github.com/spgnk/rtc/utils"
@@ -15,21 +17,128 @@ import (
 type UpPeer struct {
 	audioTrackIDs map[string]string
 	videoTrackIDs map[string]string
+	mids          map[string]string // save sdp mid - trackID
+	msids         map[string]string // save msid (stream id, track id or both) - trackID
+	sources       map[string]string // save trackID - source (camera/screen/custom)
 	mutex         sync.RWMutex
 }
 
 func (u *UpPeer) setAudioInList(trackID, codec *string) {
 	u.mutex.Lock()
 	defer u.mutex.Unlock()
+	if u.audioTrackIDs == nil {
+		u.audioTrackIDs = make(map[string]string)
+	}
 	u.audioTrackIDs[*trackID] = *codec
 }
 
 func (u *UpPeer) setVideoInList(trackID, codec *string) {
 	u.mutex.Lock()
 	defer u.mutex.Unlock()
+	if u.videoTrackIDs == nil {
+		u.videoTrackIDs = make(map[string]string)
+	}
 	u.videoTrackIDs[*trackID] = *codec
 }
 
+// SetMidTrack map media section mid of remote sdp to trackID
+func (u *UpPeer) SetMidTrack(mid, trackID string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.mids == nil {
+		u.mids = make(map[string]string)
+	}
+	u.mids[mid] = trackID
+}
+
+// SetMsidTrack map msid of remote sdp to trackID.
+// msid is "streamID trackID" of a=msid, or only one of its id
+func (u *UpPeer) SetMsidTrack(msid, trackID string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.msids == nil {
+		u.msids = make(map[string]string)
+	}
+	u.msids[msid] = trackID
+}
+
+// SetTrackSource set source (camera/screen/custom) of published trackID
+func (u *UpPeer) SetTrackSource(trackID, source string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.sources == nil {
+		u.sources = make(map[string]string)
+	}
+	u.sources[trackID] = source
+}
+
+// GetTrackSource return empty if source of trackID is not declared
+func (u *UpPeer) GetTrackSource(trackID string) string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	return u.sources[trackID]
+}
+
+// GetSourceList return copy of trackID - source
+func (u *UpPeer) GetSourceList() map[string]string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	temp := make(map[string]string, len(u.sources))
+	for trackID, source := range u.sources {
+		temp[trackID] = source
+	}
+	return temp
+}
+
+// GetMidList return copy of mid - trackID
+func (u *UpPeer) GetMidList() map[string]string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	temp := make(map[string]string, len(u.mids))
+	for mid, trackID := range u.mids {
+		temp[mid] = trackID
+	}
+	return temp
+}
+
+// GetMsidList return copy of msid - trackID
+func (u *UpPeer) GetMsidList() map[string]string {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+	temp := make(map[string]string, len(u.msids))
+	for msid, trackID := range u.msids {
+		temp[msid] = trackID
+	}
+	return temp
+}
+
+// resolve return trackID of remote track by mid, then msid, then declared track list
+func (u *UpPeer) resolve(mid, streamID, remoteTrackID, kind string) (string, bool) {
+	u.mutex.RLock()
+	defer u.mutex.RUnlock()
+
+	if trackID, has := u.mids[mid]; has && mid != "" {
+		return trackID, true
+	}
+	for _, msid := range []string{streamID + " " + remoteTrackID, remoteTrackID, streamID} {
+		if trackID, has := u.msids[msid]; has {
+			return trackID, true
+		}
+	}
+
+	lst := u.videoTrackIDs
+	if kind == "audio" {
+		lst = u.audioTrackIDs
+	}
+	// publisher may use the declared trackID as msid
+	for _, id := range []string{remoteTrackID, streamID} {
+		if _, has := lst[id]; has {
+			return id, true
+		}
+	}
+	return "", false
+}
+
 // GetVideoList linter
 func (u *UpPeer) GetVideoList() map[string]string {
 	u.mutex.RLock()
@@ -80,19 +189,42 @@ func (u *UpPeer) GetAudioArr() []string {
 	return temp
 }
 
+// defaultKeyframeInterval min duration between 2 keyframe request of a track
+const defaultKeyframeInterval = 500 * time.Millisecond
+
 var _ (Worker) = (*PeerWorker)(nil)
 
 // PeerWorker Set
 type PeerWorker struct {
-	nodeID              *string                        // node id
-	audioFwdm           utils.Fwdm                     // forward audio pkg
-	videoFwdm           utils.Fwdm                     // forward video pkg
-	peers               *utils.AdvanceMap              // save all peers with signalID
-	upList              map[string]*UpPeer             // handler all stream obj
-	tracks              map[string]*webrtc.TrackRemote // save trackID - obj to check has remote track or not
+	nodeID              *string                             // node id
+	audioFwdm           utils.Fwdm                          // forward audio pkg
+	videoFwdm           utils.Fwdm                          // forward video pkg
+	peers               *utils.AdvanceMap                   // save all peers with signalID
+	upList              map[string]*UpPeer                  // handler all stream obj
+	tracks              map[string]*webrtc.TrackRemote      // save trackID - obj to check has remote track or not
+	publishers          map[string]*peer.Peer               // save trackID - peer up is publishing this track
+	subscriptions       map[string]map[string]*subscription // save pcID - localTrackID - subscription
+	layers              map[string]map[string]bool          // save trackID - simulcast rid
+	keyframeTime        map[string]time.Time                // save trackID - last time request keyframe
+	keyframeInterval    time.Duration                       // min duration between 2 keyframe request of a track
+	screenInterval      time.Duration                       // keyframe interval of screen track
+	sources             map[string]string                   // save published trackID (and simulcast layer) - source
+	maxBitrate          int                                 // max REMB bitrate (bps) of publisher, 0 is no limit
+	bitratePercentile   int                                 // percentile of subscriber bandwidth use for publisher bitrate
+	speakers            *speakerDetector                    // smoothed audio level of audio track
+	speakerHandler      func(peerConnectionID, trackID *string)
+	deniedHandler       func(signalID, peerConnectionID *string, err error)
+	lastN               int                        // number of active speaker video forward to subscriber, 0 is all
+	recentSpeakers      []string                   // publisher pcID, most recently active first
+	pinned              map[string]map[string]bool // save pcID - video trackID always forwarded in last-N
+	lastNPaused         map[string]map[string]bool // save pcID - localTrackID paused by last-N
+	manualPaused        map[string]map[string]bool // save pcID - localTrackID paused by user
 	handleNoConnection  func(signalID *string)
+	bitrateHandler      func(signalID, peerConnectionID *string, bitrate int)
 	trackMeta           map[string]bool // save track meta for detach
 	readDeadlineHandler func(pcID, trackID *string, codec, kind string)
+	rooms               map[string]*Room // save roomID - room
+	authorizer          auth.Authorizer  // nil allow everything
 	mutex               sync.RWMutex
 	logger              utils.Log
 }
@@ -104,19 +236,44 @@ func NewPeerWorker(
 	logger utils.Log,
 ) Worker {
 	w := &PeerWorker{
-		nodeID:    nodeID,
-		audioFwdm: utils.NewForwarderMannager(*nodeID),
-		videoFwdm: utils.NewForwarderMannager(*nodeID),
-		peers:     utils.NewAdvanceMap(),
-		tracks:    make(map[string]*webrtc.TrackRemote),
-		trackMeta: make(map[string]bool),
-		upList:    upList,
+		nodeID:           nodeID,
+		audioFwdm:        utils.NewForwarderMannager(*nodeID),
+		videoFwdm:        utils.NewForwarderMannager(*nodeID),
+		peers:            utils.NewAdvanceMap(),
+		tracks:           make(map[string]*webrtc.TrackRemote),
+		publishers:       make(map[string]*peer.Peer),
+		subscriptions:    make(map[string]map[string]*subscription),
+		layers:           make(map[string]map[string]bool),
+		keyframeTime:     make(map[string]time.Time),
+		keyframeInterval: defaultKeyframeInterval,
+		screenInterval:   defaultScreenKeyframeInterval,
+		sources:          make(map[string]string),
+		speakers:         newSpeakerDetector(),
+		pinned:           make(map[string]map[string]bool),
+		lastNPaused:      make(map[string]map[string]bool),
+		manualPaused:     make(map[string]map[string]bool),
+		trackMeta:        make(map[string]bool),
+		rooms:            make(map[string]*Room),
+		upList:           upList,
 		logger: &workerLog{
 			id:     *nodeID,
 			logger: logger,
 		},
 	}
 
+	// new video subscriber wait for keyframe, ask publisher for it
+	w.videoFwdm.SetKeyframeHandler(w.requestKeyframe)
+
+	// keep recently forwarded video packet to answer NACK of subscriber
+	w.videoFwdm.SetCacheSize(utils.DefaultCacheSize)
+
+	// slow subscriber must not stall other subscriber of the same track
+	w.videoFwdm.SetPolicy(utils.PolicyDropUntilKeyframe)
+	w.audioFwdm.SetPolicy(utils.PolicyDropOldest)
+
+	// audio level of each packet for active speaker detection
+	w.audioFwdm.SetAudioLevelHandler(w.handleAudioLevel)
+
 	return w
 }
 
@@ -130,6 +287,8 @@ func (w *PeerWorker) SetHandleNoConnection(handler func(signalID *string)) {
 // Start linter
 func (w *PeerWorker) Start() error {
 	go w.countInterVal()
+	go w.controlBitrate()
+	go w.detectSpeaker()
 	return nil
 }
 
@@ -191,6 +350,10 @@ func (w *PeerWorker) AddDCConnection(
 	handleFailedDCPeer func(signalID, role, peerConnectionID *string),
 	handleCandidate func(signalID, peerConnectionID *string, candidate *webrtc.ICECandidate),
 ) (*peer.Peer, error) {
+	if err := w.authorizeDataChannel(signalID, configs.PeerConnectionID); err != nil {
+		return nil, err
+	}
+
 	// get connections
 	connections := w.getConnections(signalID)
 	if connections == nil {
@@ -237,6 +400,21 @@ func (w *PeerWorker) AddConnection(
 	if err != nil {
 		return nil, fmt.Errorf("add new connection %s err: %s", *configs.PeerConnectionID, err.Error())
 	}
+
+	pcID := *configs.PeerConnectionID
+	conn.SetPacketSource(func(trackID string, ssrc uint32, seq uint16) ([]byte, bool) {
+		return w.getCachedPacket(&pcID, &trackID, ssrc, seq)
+	})
+	conn.SetKeyframeRequestHandler(func(trackID string) {
+		w.requestKeyframe(*w.sourceOf(&pcID, &trackID))
+	})
+	conn.SetCodecSource(func(trackID string) []webrtc.RTPCodecParameters {
+		return w.getPublisherCodecs(w.sourceOf(&pcID, &trackID))
+	})
+	sID := *signalID
+	conn.SetBitrateHandler(func(bitrate int) {
+		w.handleBitrateChange(&sID, &pcID, bitrate)
+	})
 	return conn, nil
 }
 
@@ -268,6 +446,9 @@ func (w *PeerWorker) UnRegister(peerConnectionID *string) {
 
 	w.audioFwdm.UnregisterAll(*peerConnectionID)
 	w.logger.WARN(fmt.Sprintf("%s unRegister all AudioFwdm", *peerConnectionID), nil)
+
+	w.deleteSubscriptions(peerConnectionID)
+	w.deletePaused(peerConnectionID, nil)
 	// if fwdm := w.getAudioFwdm(); fwdm != nil {
 	// 	fwdm.UnregisterAll(*peerConnectionID)
 	// 	w.logger.WARN(fmt.Sprintf("%s unRegister all AudioFwdm", *peerConnectionID))
@@ -284,8 +465,19 @@ func (w *PeerWorker) UnRegisterVideo(peerConnectionID, videoTrackID *string) {
 		// 	w.logger.WARN(fmt.Sprintf("%s unRegister (%s) of VideoFwdm", *peerConnectionID, *videoTrackID))
 		// }
 
-		w.videoFwdm.Unregister(videoTrackID, peerConnectionID)
-		w.logger.WARN(fmt.Sprintf("%s unRegister (%s) of VideoFwdm", *peerConnectionID, *videoTrackID), nil)
+		// local track could be switched to other source
+		trackID := videoTrackID
+		if sub := w.getSubscription(peerConnectionID, videoTrackID); sub != nil {
+			trackID = &sub.trackID
+			if sub.prevTrackID != "" {
+				w.videoFwdm.Unregister(&sub.prevTrackID, peerConnectionID)
+			}
+			w.deleteSubscription(peerConnectionID, videoTrackID)
+		}
+		w.deletePaused(peerConnectionID, videoTrackID)
+
+		w.videoFwdm.Unregister(trackID, peerConnectionID)
+		w.logger.WARN(fmt.Sprintf("%s unRegister (%s) of VideoFwdm", *peerConnectionID, *trackID), nil)
 	}
 
 }
@@ -298,8 +490,19 @@ func (w *PeerWorker) UnRegisterAudio(peerConnectionID, audioTrackID *string) {
 		// 	fwdm.Unregister(audioTrackID, peerConnectionID)
 		// 	w.logger.WARN(fmt.Sprintf("%s unRegister (%s) in AudioFwdm", *peerConnectionID, *audioTrackID))
 		// }
-		w.audioFwdm.Unregister(audioTrackID, peerConnectionID)
-		w.logger.WARN(fmt.Sprintf("%s unRegister (%s) in AudioFwdm", *peerConnectionID, *audioTrackID), nil)
+		// local track could be switched to other source
+		trackID := audioTrackID
+		if sub := w.getSubscription(peerConnectionID, audioTrackID); sub != nil {
+			trackID = &sub.trackID
+			if sub.prevTrackID != "" {
+				w.audioFwdm.Unregister(&sub.prevTrackID, peerConnectionID)
+			}
+			w.deleteSubscription(peerConnectionID, audioTrackID)
+		}
+		w.deletePaused(peerConnectionID, audioTrackID)
+
+		w.audioFwdm.Unregister(trackID, peerConnectionID)
+		w.logger.WARN(fmt.Sprintf("%s unRegister (%s) in AudioFwdm", *peerConnectionID, *trackID), nil)
 	}
 }
 
@@ -340,6 +543,21 @@ func (w *PeerWorker) RegisterVideo(
 	videoTrackID,
 	peerConnectionID *string,
 	errHandler func(signalID, peerConnectionID, trackID *string, reason string),
+) error {
+	if err := w.authorizeSubscribe(signalID, peerConnectionID, videoTrackID, "video"); err != nil {
+		return err
+	}
+	// simulcast trackID is fed by one of its layer
+	return w.registerVideo(signalID, w.defaultSource(videoTrackID), videoTrackID, peerConnectionID, errHandler)
+}
+
+// registerVideo write data of fwd videoTrackID into local track localTrackID of peerConnectionID
+func (w *PeerWorker) registerVideo(
+	signalID,
+	videoTrackID,
+	localTrackID,
+	peerConnectionID *string,
+	errHandler func(signalID, peerConnectionID, trackID *string, reason string),
 ) error {
 	p := w.getPeer(signalID, peerConnectionID)
 	if p == nil {
@@ -366,7 +584,13 @@ func (w *PeerWorker) RegisterVideo(
 		// 	return nil
 		// }
 
-		err := p.AddVideoRTP(&trackID, peerConnectionID, wrapper.Pkg)
+		// filtered svc layer
+		if wrapper.Skip {
+			p.SkipVideoRTP(localTrackID, wrapper.Pkg)
+			return nil
+		}
+
+		err := p.AddVideoRTP(localTrackID, peerConnectionID, wrapper.Pkg)
 		if err != nil {
 			errHandler(signalID, peerConnectionID, &trackID, err.Error())
 			return err
@@ -377,7 +601,15 @@ func (w *PeerWorker) RegisterVideo(
 	}
 
 	// w.videoFwdm.Unregister(videoTrackID, p.GetPeerConnectionID())
-	w.videoFwdm.Register(*videoTrackID, *peerConnectionID, videoHandler)
+	w.checkLastN(peerConnectionID, localTrackID, videoTrackID)
+	w.subscribe(w.videoFwdm, p, &subscription{
+		signalID:     *signalID,
+		pcID:         *peerConnectionID,
+		localTrackID: *localTrackID,
+		trackID:      *videoTrackID,
+		kind:         "video",
+		errHandler:   errHandler,
+	}, videoHandler)
 	return nil
 }
 
@@ -387,6 +619,20 @@ func (w *PeerWorker) RegisterAudio(
 	audioTrackID,
 	peerConnectionID *string,
 	errHandler func(signalID, peerConnectionID, trackID *string, reason string),
+) error {
+	if err := w.authorizeSubscribe(signalID, peerConnectionID, audioTrackID, "audio"); err != nil {
+		return err
+	}
+	return w.registerAudio(signalID, audioTrackID, audioTrackID, peerConnectionID, errHandler)
+}
+
+// registerAudio write data of fwd audioTrackID into local track localTrackID of peerConnectionID
+func (w *PeerWorker) registerAudio(
+	signalID,
+	audioTrackID,
+	localTrackID,
+	peerConnectionID *string,
+	errHandler func(signalID, peerConnectionID, trackID *string, reason string),
 ) error {
 	p := w.getPeer(signalID, peerConnectionID)
 	if p == nil {
@@ -413,7 +659,7 @@ func (w *PeerWorker) RegisterAudio(
 		// 	return nil
 		// }
 
-		err := p.AddAudioRTP(&trackID, peerConnectionID, wrapper.Pkg)
+		err := p.AddAudioRTP(localTrackID, peerConnectionID, wrapper.Pkg)
 		if err != nil {
 			errHandler(signalID, peerConnectionID, &trackID, err.Error())
 			return err
@@ -424,10 +670,47 @@ func (w *PeerWorker) RegisterAudio(
 	}
 
 	// w.audioFwdm.Unregister(audioTrackID, p.GetPeerConnectionID())
-	w.audioFwdm.Register(*audioTrackID, *peerConnectionID, audioHandler)
+	w.subscribe(w.audioFwdm, p, &subscription{
+		signalID:     *signalID,
+		pcID:         *peerConnectionID,
+		localTrackID: *localTrackID,
+		trackID:      *audioTrackID,
+		kind:         "audio",
+		errHandler:   errHandler,
+	}, audioHandler)
 	return nil
 }
 
+// SwitchSource feed local track localTrackID of pcID with data of newTrackID fwd.
+// Sequence number and timestamp of local track continue without jump
+func (w *PeerWorker) SwitchSource(pcID, localTrackID, newTrackID *string) error {
+	sub := w.getSubscription(pcID, localTrackID)
+	if sub == nil {
+		return fmt.Errorf("%s_%s %s", *pcID, *localTrackID, errs.ErrW002.Error())
+	}
+
+	if sub.trackID == *newTrackID {
+		return nil
+	}
+
+	// claims list base trackID, simulcast layer is authorized by its track
+	baseID := w.baseTrackID(*newTrackID)
+	if err := w.authorizeSubscribe(&sub.signalID, pcID, &baseID, sub.kind); err != nil {
+		return err
+	}
+
+	w.logger.INFO(fmt.Sprintf("%s switch local track %s from %s to %s", *pcID, *localTrackID, sub.trackID, *newTrackID), nil)
+
+	switch sub.kind {
+	case "video":
+		return w.registerVideo(&sub.signalID, newTrackID, localTrackID, pcID, sub.errHandler)
+	case "audio":
+		return w.registerAudio(&sub.signalID, newTrackID, localTrackID, pcID, sub.errHandler)
+	default:
+		return fmt.Errorf("wrong kind of subscription: %s", sub.kind)
+	}
+}
+
 // GetStates return all pcID - states
 func (w *PeerWorker) GetStates() map[string]string {
 	temp := make(map[string]string)
@@ -445,6 +728,16 @@ func (w *PeerWorker) GetStates() map[string]string {
 	return temp
 }
 
+// Renegotiate send a new offer of peer to its negotiation handler.
+// Track change of peer with handleOnNegotiationNeeded is renegotiated automatically
+func (w *PeerWorker) Renegotiate(signalID, peerConnectionID *string) error {
+	p := w.getPeer(signalID, peerConnectionID)
+	if p == nil {
+		return fmt.Errorf("[%s-%s] %s", *signalID, *peerConnectionID, errs.ErrP002)
+	}
+	return p.Renegotiate()
+}
+
 // GetAllConnectionID return signalID - [streamID]
 func (w *PeerWorker) GetAllConnectionID() map[string][]string {
 	tmp := make(map[string][]string)
@@ -476,6 +769,11 @@ func (w *PeerWorker) AddUpList(peerConnectionID *string, c *UpPeer) {
 	w.addUpList(peerConnectionID, c)
 }
 
+// HasPeerConnectionID linter
+func (w *PeerWorker) HasPeerConnectionID(peerConnectionID *string) bool {
+	return w.getUpPeer(peerConnectionID) != nil || w.findPeer(peerConnectionID) != nil
+}
+
 // AppendUpList linter
 func (w *PeerWorker) AppendUpList(pcID *string, obj *UpPeer) {
 	currObj := w.getUpPeer(pcID)
@@ -485,28 +783,56 @@ func (w *PeerWorker) AppendUpList(pcID *string, obj *UpPeer) {
 		return
 	}
 
-	for trackID, codec := range obj.audioTrackIDs {
+	for trackID, codec := range obj.GetAudioList() {
 		currObj.setAudioInList(&trackID, &codec)
 	}
 
-	for trackID, codec := range obj.videoTrackIDs {
+	for trackID, codec := range obj.GetVideoList() {
 		currObj.setVideoInList(&trackID, &codec)
 	}
+
+	for mid, trackID := range obj.GetMidList() {
+		currObj.SetMidTrack(mid, trackID)
+	}
+
+	for msid, trackID := range obj.GetMsidList() {
+		currObj.SetMsidTrack(msid, trackID)
+	}
+
+	for trackID, source := range obj.GetSourceList() {
+		currObj.SetTrackSource(trackID, source)
+	}
 }
 
 // handle peer remotetrack with streamID
 func (w *PeerWorker) handleOnTrack(signalID, peerConnectionID *string, remoteTrack *webrtc.TrackRemote) {
 	kind := remoteTrack.Kind().String()
 	// find trackId in stream ọbject
-	trackID, err := w.findTrackID(peerConnectionID, &kind)
+	trackID, err := w.findTrackID(signalID, peerConnectionID, remoteTrack)
 	codec := remoteTrack.Codec().MimeType
 	if err != nil {
 		w.logger.WARN(err.Error()+" so use remoteTrackID", nil)
 		trackID = remoteTrack.ID()
 	}
 
+	// simulcast publisher, each layer has its own fwd
+	baseID := trackID
+	rid := remoteTrack.RID()
+	if rid != "" && kind == "video" {
+		trackID = utils.LayerTrackID(baseID, rid)
+	}
+
 	w.logger.INFO(fmt.Sprintf("(%s_%s) Has remote track of id %s_%s", trackID, codec, *signalID, *peerConnectionID), nil)
 
+	if err := w.authorizePublish(signalID, peerConnectionID, &baseID, kind); err != nil {
+		w.denyPublish(signalID, peerConnectionID, remoteTrack, err)
+		return
+	}
+
+	source := w.declaredSource(peerConnectionID, &baseID, kind)
+	w.setTrackSource(&baseID, source)
+	w.setTrackSource(&trackID, source)
+
 	var fwdm utils.Fwdm
 	switch kind {
 	case "video":
@@ -517,7 +843,35 @@ func (w *PeerWorker) handleOnTrack(signalID, peerConnectionID *string, remoteTra
 		return
 	}
 
-	go w.pushToFwd(fwdm, remoteTrack, &trackID, &kind, peerConnectionID)
+	if trackID == baseID {
+		go func() {
+			w.handleTrackPublished(signalID, &baseID, &kind, &codec)
+			w.pushToFwd(fwdm, remoteTrack, w.getPeer(signalID, peerConnectionID), &trackID, &kind, peerConnectionID)
+			w.deleteTrackSource(&baseID)
+			w.handleTrackUnpublished(signalID, &baseID)
+		}()
+		return
+	}
+
+	// subscriber registered before the first layer come is moved to this layer
+	first := w.addLayer(&baseID, rid)
+	if first {
+		w.moveToLayer(&baseID, &trackID)
+	}
+
+	go func() {
+		// simulcast track is published by its first layer and unpublished with the last one
+		if first {
+			w.handleTrackPublished(signalID, &baseID, &kind, &codec)
+		}
+		w.pushToFwd(fwdm, remoteTrack, w.getPeer(signalID, peerConnectionID), &trackID, &kind, peerConnectionID)
+		w.deleteLayer(&baseID, rid)
+		w.deleteTrackSource(&trackID)
+		if len(w.GetLayers(&baseID)) == 0 {
+			w.deleteTrackSource(&baseID)
+			w.handleTrackUnpublished(signalID, &baseID)
+		}
+	}()
 }
 
 // ReadRTP is a convenience method that wraps Read and unmarshals for you.
@@ -535,11 +889,12 @@ func (w *PeerWorker) handleOnTrack(signalID, peerConnectionID *string, remoteTra
 // 	return r, attributes, nil
 // }
 
-func (w *PeerWorker) pushToFwd(fwdm utils.Fwdm, remoteTrack *webrtc.TrackRemote, trackID, kind, peerConnectionID *string) {
+func (w *PeerWorker) pushToFwd(fwdm utils.Fwdm, remoteTrack *webrtc.TrackRemote, publisher *peer.Peer, trackID, kind, peerConnectionID *string) {
 	var pkg *rtp.Packet
 	var err error
 	var i int
 	var b *[]byte
+	var lastFwd *utils.Forwarder
 	codec := remoteTrack.Codec().MimeType
 
 	// readDeadLine := 16 * time.Second
@@ -559,6 +914,11 @@ func (w *PeerWorker) pushToFwd(fwdm utils.Fwdm, remoteTrack *webrtc.TrackRemote,
 
 	w.setRemoteTrack(trackID, remoteTrack)
 	defer w.deleteRemoteTrack(trackID)
+	w.setPublisher(trackID, publisher)
+	defer w.deletePublisher(trackID, publisher)
+	if *kind == "audio" {
+		defer w.speakers.remove(*trackID)
+	}
 	for {
 		b = rlBufPool.Get().(*[]byte)
 		// err = remoteTrack.SetReadDeadline(time.Now().Add(readDeadLine))
@@ -594,6 +954,18 @@ func (w *PeerWorker) pushToFwd(fwdm utils.Fwdm, remoteTrack *webrtc.TrackRemote,
 			fwd = fwdm.AddNewForwarder(*trackID)
 		}
 
+		// fwd need codec to detect keyframe
+		if fwd != nil && fwd != lastFwd {
+			fwd.SetCodec(codec)
+			if *kind == "audio" && publisher != nil {
+				fwd.SetAudioLevelID(publisher.GetHeaderExtensionID(remoteTrack, utils.AudioLevelURI))
+			}
+			if *kind == "video" && publisher != nil {
+				fwd.SetDependencyDescriptorID(publisher.GetHeaderExtensionID(remoteTrack, utils.DependencyDescriptorURI))
+			}
+			lastFwd = fwd
+		}
+
 		// pushing data to fwd
 		if fwd != nil {
 			fwd.Push(&utils.Wrapper{
@@ -615,47 +987,149 @@ func (w *PeerWorker) pushToFwd(fwdm utils.Fwdm, remoteTrack *webrtc.TrackRemote,
 	}
 }
 
-// findTrackID use only for peer up
-func (w *PeerWorker) findTrackID(peerConnectionID, kind *string) (string, error) {
+// findTrackID use only for peer up. trackID is resolved by mid/msid of remote sdp,
+// a list of only one trackID of the kind is used for any remote track
+func (w *PeerWorker) findTrackID(signalID, peerConnectionID *string, remoteTrack *webrtc.TrackRemote) (string, error) {
 	var id string
 	lst := w.getUpList()
 	if lst == nil {
 		return id, errs.ErrPS0041
 	}
 
-	obj := lst[*peerConnectionID]
+	obj := w.getUpPeer(peerConnectionID)
 	if obj == nil {
 		return id, fmt.Errorf("%s %s", *peerConnectionID, errs.ErrPS0042.Error())
 	}
 
+	kind := remoteTrack.Kind().String()
+	var mid string
+	if p := w.getPeer(signalID, peerConnectionID); p != nil {
+		mid = p.GetMid(remoteTrack)
+	}
+	if trackID, ok := obj.resolve(mid, remoteTrack.StreamID(), remoteTrack.ID(), kind); ok {
+		return trackID, nil
+	}
+
 	var arr []string
-	switch *kind {
+	switch kind {
 	case "video":
 		arr = obj.GetVideoArr()
 	case "audio":
 		arr = obj.GetAudioArr()
 	default:
-		return id, fmt.Errorf("wrong kind track id: %s", *kind)
+		return id, fmt.Errorf("wrong kind track id: %s", kind)
 	}
 
 	length := len(arr)
 	switch length {
 	case 0:
-		return id, fmt.Errorf("%s %s %s", *peerConnectionID, *kind, errs.ErrPS0043.Error())
+		return id, fmt.Errorf("%s %s %s", *peerConnectionID, kind, errs.ErrPS0043.Error())
 	case 1:
 		id = arr[0] // there is only one id in arr if peer up
 	default:
-		return id, fmt.Errorf("cannot find track id in %s track ids with current length %d", *kind, length)
+		return id, fmt.Errorf("cannot find track id of mid %s msid %s %s in %s track ids with current length %d", mid, remoteTrack.StreamID(), remoteTrack.ID(), kind, length)
 	}
 
 	return id, nil
 }
 
+// requestKeyframe send PLI to the peer which is publishing trackID.
+// Request of all subscriber is aggregated, at most one PLI per keyframe interval
+func (w *PeerWorker) requestKeyframe(trackID string) {
+	remoteTrack := w.getRemoteTrack(&trackID)
+	publisher := w.getPublisher(&trackID)
+	if remoteTrack == nil || publisher == nil {
+		return
+	}
+	if !w.allowKeyframe(&trackID) {
+		return
+	}
+	publisher.SendPictureLossIndicationTo(uint32(remoteTrack.SSRC()))
+}
+
+// SetKeyframeInterval set min duration between 2 keyframe request send to a publisher track
+func (w *PeerWorker) SetKeyframeInterval(interval time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.keyframeInterval = interval
+}
+
+// SetVideoDropPolicy set drop policy of peerConnectionID for video trackID
+func (w *PeerWorker) SetVideoDropPolicy(peerConnectionID, trackID *string, policy utils.DropPolicy) {
+	w.videoFwdm.SetClientPolicy(*w.sourceOf(peerConnectionID, trackID), *peerConnectionID, policy)
+}
+
+// SetAudioDropPolicy set drop policy of peerConnectionID for audio trackID
+func (w *PeerWorker) SetAudioDropPolicy(peerConnectionID, trackID *string, policy utils.DropPolicy) {
+	w.audioFwdm.SetClientPolicy(*w.sourceOf(peerConnectionID, trackID), *peerConnectionID, policy)
+}
+
+// SetSVCLayer set max vp9 spatial/temporal layer of video trackID for peerConnectionID.
+// Negative value is no limit
+func (w *PeerWorker) SetSVCLayer(peerConnectionID, trackID *string, spatial, temporal int) {
+	w.videoFwdm.SetClientLayer(*w.sourceOf(peerConnectionID, trackID), *peerConnectionID, spatial, temporal)
+}
+
+// GetVideoDropped return number of video packet dropped for peerConnectionID
+func (w *PeerWorker) GetVideoDropped(peerConnectionID, trackID *string) uint64 {
+	return w.videoFwdm.GetClientDropped(*w.sourceOf(peerConnectionID, trackID), *peerConnectionID)
+}
+
+// GetAudioDropped return number of audio packet dropped for peerConnectionID
+func (w *PeerWorker) GetAudioDropped(peerConnectionID, trackID *string) uint64 {
+	return w.audioFwdm.GetClientDropped(*w.sourceOf(peerConnectionID, trackID), *peerConnectionID)
+}
+
+// EstimatedBitrate return estimated bandwidth of peer down peerConnectionID in bps, 0 if not available
+func (w *PeerWorker) EstimatedBitrate(peerConnectionID *string) int {
+	if p := w.findPeer(peerConnectionID); p != nil {
+		return p.EstimatedBitrate()
+	}
+	return 0
+}
+
+// SetHandleBitrateChange set handler receive new estimated bitrate of peer down
+func (w *PeerWorker) SetHandleBitrateChange(handler func(signalID, peerConnectionID *string, bitrate int)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.bitrateHandler = handler
+}
+
+func (w *PeerWorker) handleBitrateChange(signalID, peerConnectionID *string, bitrate int) {
+	w.mutex.RLock()
+	handler := w.bitrateHandler
+	w.mutex.RUnlock()
+	if handler != nil {
+		handler(signalID, peerConnectionID, bitrate)
+	}
+}
+
 // GetRemoteTrack linter
 func (w *PeerWorker) GetRemoteTrack(trackID *string) *webrtc.TrackRemote {
 	return w.getRemoteTrack(trackID)
 }
 
+// GetLogger linter
+func (w *PeerWorker) GetLogger() utils.Log {
+	return w.logger
+}
+
+// GetVideoCodec return codec mode (vp8/vp9/h264/h265/av1) of publishing video trackID, vp8 if trackID is not published yet
+func (w *PeerWorker) GetVideoCodec(trackID *string) string {
+	remoteTrack := w.getRemoteTrack(trackID)
+	if remoteTrack == nil {
+		// simulcast trackID is published by its layer
+		if layers := w.GetLayers(trackID); len(layers) > 0 {
+			layerID := utils.LayerTrackID(*trackID, layers[0])
+			remoteTrack = w.getRemoteTrack(&layerID)
+		}
+	}
+	if remoteTrack == nil {
+		return utils.ModeVP8
+	}
+	return utils.GetModeType(remoteTrack.Codec().MimeType)
+}
+
 // GetVideoReceiveTime linter
 func (w *PeerWorker) GetVideoReceiveTime() map[string]int64 {
 	return w.videoFwdm.GetLastTimeReceive()